@@ -0,0 +1,70 @@
+package airtablewatcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ActiveHoursFormat is the time of day format of an active hours window, e.g. "09:00-17:00"
+const ActiveHoursFormat = "15:04"
+
+// activeHours is a daily window between two times of day, in minutes since midnight
+type activeHours struct {
+	start, end int
+}
+
+// parseActiveHours Parse a window like "09:00-17:00". A window whose end is before its start spans midnight
+func parseActiveHours(value string) (activeHours, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return activeHours{}, fmt.Errorf("active hours %q should look like 09:00-17:00", value)
+	}
+	minutes := [2]int{}
+	for i, part := range parts {
+		clock, err := time.Parse(ActiveHoursFormat, strings.TrimSpace(part))
+		if err != nil {
+			return activeHours{}, fmt.Errorf("invalid active hours %q: %w", value, err)
+		}
+		minutes[i] = clock.Hour()*60 + clock.Minute()
+	}
+	return activeHours{start: minutes[0], end: minutes[1]}, nil
+}
+
+// contains Check if the time of day of now is inside the window
+func (a activeHours) contains(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	if a.start <= a.end {
+		return minute >= a.start && minute < a.end
+	}
+	return minute >= a.start || minute < a.end
+}
+
+// active Check if now is inside the active hours window in the config table.
+// Always active if ActiveHoursConfigKey is not set or the key is missing
+func (t *Watcher) active(now time.Time) (bool, error) {
+	if t.ActiveHoursConfigKey == "" {
+		return true, nil
+	}
+	config, err := t.GetAllConfig()
+	if err != nil {
+		return false, err
+	}
+	value, ok := config[t.ActiveHoursConfigKey]
+	if !ok || strings.TrimSpace(value) == "" {
+		return true, nil
+	}
+	window, err := parseActiveHours(value)
+	if err != nil {
+		return false, err
+	}
+
+	location := time.Local
+	if name := config[t.TimezoneConfigKey]; t.TimezoneConfigKey != "" && name != "" {
+		if location, err = time.LoadLocation(name); err != nil {
+			return false, fmt.Errorf("invalid timezone %q: %w", name, err)
+		}
+	}
+
+	return window.contains(now.In(location)), nil
+}
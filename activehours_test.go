@@ -0,0 +1,62 @@
+package airtablewatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActiveHoursContains(t *testing.T) {
+	day := func(hour, minute int) time.Time { return time.Date(2020, 1, 1, hour, minute, 0, 0, time.UTC) }
+
+	window, err := parseActiveHours("09:00-17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !window.contains(day(9, 0)) || !window.contains(day(16, 59)) || window.contains(day(17, 0)) || window.contains(day(8, 59)) {
+		t.Errorf("Incorrect business hours window")
+	}
+
+	overnight, _ := parseActiveHours("22:00 - 06:00")
+	if !overnight.contains(day(23, 0)) || !overnight.contains(day(5, 0)) || overnight.contains(day(12, 0)) {
+		t.Errorf("Incorrect overnight window")
+	}
+
+	if _, err := parseActiveHours("9am to 5pm"); err == nil {
+		t.Errorf("Expected error for invalid window")
+	}
+}
+
+func TestActiveHoursConfig(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	// A one minute window twelve hours from now
+	now := time.Now().UTC()
+	start := now.Add(time.Hour * 12).Format(ActiveHoursFormat)
+	end := now.Add(time.Hour*12 + time.Minute).Format(ActiveHoursFormat)
+	hoursID := fake.AddRecord("Config", map[string]interface{}{"Key": "ActiveHours", "Value": start + "-" + end})
+	fake.AddRecord("Config", map[string]interface{}{"Key": "Timezone", "Value": "UTC"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.ActiveHoursConfigKey = "ActiveHours"
+	watcher.TimezoneConfigKey = "Timezone"
+
+	ran := make(chan string, 10)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, recordRuns(ran))
+
+	// Outside the window
+	expectRuns(t, watcher, ran, 0)
+	watcher.SkipPollsOutsideActiveHours = true
+	lists := fake.ListRequests("Tasks")
+	expectRuns(t, watcher, ran, 0)
+	if fake.ListRequests("Tasks") != lists {
+		t.Errorf("Rows were fetched outside active hours")
+	}
+
+	// Invalid windows don't dispatch either
+	fake.SetField("Config", hoursID, "Value", "whenever")
+	expectRuns(t, watcher, ran, 0)
+
+	// Without a window it is always active
+	fake.SetField("Config", hoursID, "Value", "")
+	expectRuns(t, watcher, ran, 1)
+}
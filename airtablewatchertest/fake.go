@@ -0,0 +1,69 @@
+// Package airtablewatchertest provides a watcher backed by an in-memory airtable,
+// so code built on airtablewatcher can be tested without a live base
+package airtablewatchertest
+
+import (
+	"github.com/vertoforce/airtablewatcher"
+	"github.com/vertoforce/airtablewatcher/internal/fakeairtable"
+)
+
+// Fake key and base accepted by the airtable client
+const (
+	FakeAirtableKey  = "keyFAKEFAKEFAKEFA"
+	FakeAirtableBase = "appFAKEFAKEFAKEFA"
+)
+
+// FakeWatcher is a real Watcher whose requests are served by an in-memory airtable.
+// Use the embedded Watcher as usual (GetRows, GetRow, SetRow, RegisterFunction, ...),
+// and RunOnce to drive triggers synchronously
+type FakeWatcher struct {
+	*airtablewatcher.Watcher
+	server *fakeairtable.Server
+}
+
+// NewFakeWatcher Create a watcher backed by an empty in-memory airtable, Close it when done
+func NewFakeWatcher() (*FakeWatcher, error) {
+	watcher, err := airtablewatcher.NewWatcher(FakeAirtableKey, FakeAirtableBase)
+	if err != nil {
+		return nil, err
+	}
+	server := fakeairtable.New()
+	watcher.Transport = server.Transport()
+
+	return &FakeWatcher{Watcher: watcher, server: server}, nil
+}
+
+// Close Shut down the in-memory airtable
+func (f *FakeWatcher) Close() {
+	f.server.Close()
+}
+
+// AddRow Add a row to a table directly, returning its record ID
+func (f *FakeWatcher) AddRow(tableName string, fields map[string]interface{}) string {
+	return f.server.AddRecord(tableName, fields)
+}
+
+// DeleteRowDirect Remove a row from a table directly, bypassing the watcher
+func (f *FakeWatcher) DeleteRowDirect(tableName, recordID string) {
+	f.server.DeleteRecord(tableName, recordID)
+}
+
+// SetField Change a single field on a row directly, bypassing the watcher
+func (f *FakeWatcher) SetField(tableName, recordID, fieldName string, value interface{}) {
+	f.server.SetField(tableName, recordID, fieldName, value)
+}
+
+// Fields Get a copy of a row's fields, nil if the row does not exist
+func (f *FakeWatcher) Fields(tableName, recordID string) map[string]interface{} {
+	return f.server.Record(tableName, recordID)
+}
+
+// RowIDs Get the record IDs in a table, in insertion order
+func (f *FakeWatcher) RowIDs(tableName string) []string {
+	return f.server.RecordIDs(tableName)
+}
+
+// Requests Get the number of requests the watcher has made
+func (f *FakeWatcher) Requests() int {
+	return f.server.Requests()
+}
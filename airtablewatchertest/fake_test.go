@@ -0,0 +1,36 @@
+package airtablewatchertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vertoforce/airtablewatcher"
+)
+
+func TestFakeWatcher(t *testing.T) {
+	fake, err := NewFakeWatcher()
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	defer fake.Close()
+	id := fake.AddRow("Tasks", map[string]interface{}{"State": "ToDo"})
+
+	fake.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *airtablewatcher.Watcher, tableName string, row *airtablewatcher.Row) {
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+	})
+
+	// The action has finished by the time RunOnce returns
+	if err := fake.RunOnce(context.Background()); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if fake.Fields("Tasks", id)["State"] != "Done" {
+		t.Errorf("Action did not run")
+	}
+
+	row, err := fake.GetRow("Tasks", id)
+	if err != nil || row.GetFieldString("State") != "Done" {
+		t.Errorf("Could not read back row")
+	}
+}
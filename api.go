@@ -0,0 +1,107 @@
+package airtablewatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fabioberger/airtable-go"
+)
+
+// airtableAPIURL is the root of the airtable REST API
+var airtableAPIURL = "https://api.airtable.com/v0"
+
+// rateLimitRetryDelay matches the delay the airtable client waits after a 429
+const rateLimitRetryDelay = 5 * time.Second
+
+// tablePath Get the API path of a table (and optionally a record) in this base
+func (t *Watcher) tablePath(tableName string, recordID ...string) string {
+	path := t.airtableBase + "/" + url.PathEscape(tableName)
+	for _, id := range recordID {
+		path += "/" + url.PathEscape(id)
+	}
+	return path
+}
+
+// request Perform a raw request against the airtable API and return the response body.
+// path is relative to the API root, e.g. "appXXX/Tasks".
+// Non 2xx responses are returned as an airtable.Error
+func (t *Watcher) request(ctx context.Context, method, path string, query url.Values, body interface{}) ([]byte, error) {
+	endpoint := airtableAPIURL + "/" + strings.TrimPrefix(path, "/")
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	for {
+		var bodyReader io.Reader
+		if body != nil {
+			JSON, err := json.Marshal(body)
+			if err != nil {
+				return nil, fmt.Errorf("error encoding request: %w", err)
+			}
+			bodyReader = bytes.NewReader(JSON)
+		}
+		req, err := http.NewRequest(method, endpoint, bodyReader)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+t.airtableKey)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := t.AirtableClient.HTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		rawBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && t.AirtableClient.ShouldRetryIfRateLimited {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(rateLimitRetryDelay):
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, parseAirtableError(resp.StatusCode, rawBody)
+		}
+
+		return rawBody, nil
+	}
+}
+
+// parseAirtableError Convert an error response body to an airtable.Error
+func parseAirtableError(statusCode int, rawBody []byte) error {
+	apiErr := airtable.Error{StatusCode: statusCode, Type: http.StatusText(statusCode)}
+
+	// The error is either an object with a type and message, or just a type string
+	response := struct {
+		Error json.RawMessage `json:"error"`
+	}{}
+	if err := json.Unmarshal(rawBody, &response); err != nil || len(response.Error) == 0 {
+		return apiErr
+	}
+	if err := json.Unmarshal(response.Error, &apiErr); err == nil {
+		apiErr.StatusCode = statusCode
+		return apiErr
+	}
+	errorType := ""
+	if err := json.Unmarshal(response.Error, &errorType); err == nil {
+		apiErr.Type = errorType
+	}
+	return apiErr
+}
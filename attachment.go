@@ -22,3 +22,22 @@ type AirtableAttachment struct {
 //
 // It can be used in an Update/Set request, or creating a record
 type AirtableAttachments []AirtableAttachment
+
+// AttachmentUpload is the part of an attachment airtable accepts when uploading
+type AttachmentUpload struct {
+	// url airtable will download the file from
+	URL string `json:"url"`
+	// filename, e.g. "foo.jpg"
+	Filename string `json:"filename,omitempty"`
+}
+
+// UploadPayload Get the attachments with only the fields airtable accepts for uploads.
+//
+// Use it as the value of an attachment field in SetRow
+func (a AirtableAttachments) UploadPayload() []AttachmentUpload {
+	uploads := make([]AttachmentUpload, 0, len(a))
+	for _, attachment := range a {
+		uploads = append(uploads, AttachmentUpload{URL: attachment.URL, Filename: attachment.Filename})
+	}
+	return uploads
+}
@@ -0,0 +1,22 @@
+package airtablewatcher
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUploadPayload(t *testing.T) {
+	attachments := AirtableAttachments{
+		{ID: "attAAAAAAAAAAAAAA", URL: "https://dl.airtable.com/foo.jpg", Filename: "foo.jpg", Size: 100, Type: "image/jpeg", Width: 10, Height: 10},
+		{URL: "https://example.com/bar.pdf"},
+	}
+	JSON, err := json.Marshal(attachments.UploadPayload())
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	expected := `[{"url":"https://dl.airtable.com/foo.jpg","filename":"foo.jpg"},{"url":"https://example.com/bar.pdf"}]`
+	if string(JSON) != expected {
+		t.Errorf("Incorrect payload %s", JSON)
+	}
+}
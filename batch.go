@@ -0,0 +1,153 @@
+package airtablewatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// MaxBatchSize is the most records airtable accepts in a single create, update or delete request
+const MaxBatchSize = 10
+
+// recordUpdate is one record of a batch update request
+type recordUpdate struct {
+	ID     string                 `json:"id"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// updateRecords PATCH the fields of many records, MaxBatchSize at a time.
+// Returns how many records were updated before any error
+func (t *Watcher) updateRecords(ctx context.Context, tableName string, updates []recordUpdate) (int, error) {
+	defer t.InvalidateTable(tableName)
+
+	updated := 0
+	for _, batchRange := range batches(len(updates)) {
+		batch := updates[batchRange[0]:batchRange[1]]
+		err := t.Retry.do(ctx, func() error {
+			_, err := t.request(ctx, "PATCH", t.tablePath(tableName), nil, map[string]interface{}{"records": batch})
+			return err
+		})
+		if err != nil {
+			return updated, fmt.Errorf("error updating records: %w", err)
+		}
+		updated += len(batch)
+	}
+
+	return updated, nil
+}
+
+// batches Split n items into index ranges of at most MaxBatchSize
+func batches(n int) [][2]int {
+	ranges := [][2]int{}
+	for start := 0; start < n; start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > n {
+			end = n
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// createRecords POST new records, MaxBatchSize at a time, returning the records created before any error.
+// Creates are not retried since a failed response may still have created the records
+func (t *Watcher) createRecords(ctx context.Context, tableName string, rows []map[string]interface{}) ([]Row, error) {
+	defer t.InvalidateTable(tableName)
+
+	created := []Row{}
+	for _, batch := range batches(len(rows)) {
+		records := []map[string]interface{}{}
+		for _, fields := range rows[batch[0]:batch[1]] {
+			records = append(records, map[string]interface{}{"fields": fields})
+		}
+		rawBody, err := t.request(ctx, "POST", t.tablePath(tableName), nil, map[string]interface{}{"records": records})
+		if err != nil {
+			return created, fmt.Errorf("error creating records: %w", err)
+		}
+		page := listPage{}
+		if err := json.Unmarshal(rawBody, &page); err != nil {
+			return created, fmt.Errorf("error decoding created records: %w", err)
+		}
+		t.withNumberFormat(page.Records)
+		created = append(created, page.Records...)
+	}
+
+	return created, nil
+}
+
+// recordIDs Get the IDs of rows
+func recordIDs(rows []Row) []string {
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids
+}
+
+// withCreateDefaults Copy fields with the table's CreateDefaults filled in underneath
+func (t *Watcher) withCreateDefaults(tableName string, fields map[string]interface{}) map[string]interface{} {
+	defaults := t.CreateDefaults[tableName]
+	merged := make(map[string]interface{}, len(defaults)+len(fields))
+	for name, value := range defaults {
+		merged[name] = value
+	}
+	for name, value := range fields {
+		merged[name] = value
+	}
+	return merged
+}
+
+// BatchCreateRows Create rows in a table, MaxBatchSize per request, returning them with their new IDs.
+// The table's CreateDefaults are filled in under each row's fields.
+// If a batch fails the rows created so far are returned with the error, see BestEffortTransaction to undo them
+func (t *Watcher) BatchCreateRows(tableName string, rows []map[string]interface{}) ([]Row, error) {
+	withDefaults := make([]map[string]interface{}, 0, len(rows))
+	for _, fields := range rows {
+		withDefaults = append(withDefaults, t.withCreateDefaults(tableName, fields))
+	}
+	return t.createRecords(context.Background(), tableName, withDefaults)
+}
+
+// deleteRecords DELETE records, MaxBatchSize at a time, returning the IDs deleted before any error
+func (t *Watcher) deleteRecords(ctx context.Context, tableName string, recordIDs []string) ([]string, error) {
+	defer t.InvalidateTable(tableName)
+
+	deleted := []string{}
+	for _, batch := range batches(len(recordIDs)) {
+		ids := recordIDs[batch[0]:batch[1]]
+		query := url.Values{"records[]": ids}
+		err := t.Retry.do(ctx, func() error {
+			_, err := t.request(ctx, "DELETE", t.tablePath(tableName), query, nil)
+			return err
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting records: %w", err)
+		}
+		deleted = append(deleted, ids...)
+	}
+
+	return deleted, nil
+}
+
+// TransitionMatching Set field to `to` on every row where it is currently `from`, returning how many rows changed.
+// Rows are found with a server side filter and updated in batches, if a batch fails the count of rows
+// already changed is returned with the error
+func (t *Watcher) TransitionMatching(tableName, field, from, to string) (int, error) {
+	ctx := context.Background()
+	query := ListOptions{
+		FilterByFormula: formulaField(field) + " = " + formulaString(from),
+		Fields:          []string{field},
+	}.query()
+	rows, err := t.listRecords(ctx, tableName, query)
+	if err != nil {
+		return 0, fmt.Errorf("error finding rows: %w", err)
+	}
+
+	updates := make([]recordUpdate, 0, len(rows))
+	for _, row := range rows {
+		updates = append(updates, recordUpdate{ID: row.ID, Fields: map[string]interface{}{field: to}})
+	}
+
+	return t.updateRecords(ctx, tableName, updates)
+}
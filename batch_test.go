@@ -0,0 +1,73 @@
+package airtablewatcher
+
+import (
+	"testing"
+)
+
+func TestTransitionMatching(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	stuck := []string{}
+	for i := 0; i < MaxBatchSize+3; i++ {
+		stuck = append(stuck, fake.AddRecord("Tasks", map[string]interface{}{"State": "Stuck"}))
+	}
+	done := fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	quoted := fake.AddRecord("Tasks", map[string]interface{}{"State": "Stuck's"})
+	watcher := newFakeWatcher(t, fake)
+
+	changed, err := watcher.TransitionMatching("Tasks", "State", "Stuck", "ToDo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != len(stuck) {
+		t.Errorf("Expected %d rows changed, got %d", len(stuck), changed)
+	}
+	for _, id := range stuck {
+		if state := fake.Record("Tasks", id)["State"]; state != "ToDo" {
+			t.Errorf("Row %s not transitioned: %v", id, state)
+		}
+	}
+	if fake.Record("Tasks", done)["State"] != "Done" {
+		t.Errorf("Non matching row was changed")
+	}
+
+	// Values are quoted safely
+	if changed, err := watcher.TransitionMatching("Tasks", "State", "Stuck's", "ToDo"); err != nil || changed != 1 {
+		t.Errorf("Incorrect quoted transition %d %v", changed, err)
+	}
+	if fake.Record("Tasks", quoted)["State"] != "ToDo" {
+		t.Errorf("Quoted row not transitioned")
+	}
+}
+
+func TestBatchCreateRowsDefaults(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	watcher := newFakeWatcher(t, fake)
+	watcher.CreateDefaults = map[string]map[string]interface{}{
+		"Tasks": {"Source": "watcher", "State": "New"},
+	}
+
+	rows, err := watcher.BatchCreateRows("Tasks", []map[string]interface{}{
+		{"Name": "Plain"},
+		{"Name": "Started", "State": "ToDo"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].ID == "" {
+		t.Fatalf("Incorrect created rows %v", rows)
+	}
+	if record := fake.Record("Tasks", rows[0].ID); record["Source"] != "watcher" || record["State"] != "New" {
+		t.Errorf("Defaults not applied %v", record)
+	}
+	if record := fake.Record("Tasks", rows[1].ID); record["State"] != "ToDo" || record["Source"] != "watcher" {
+		t.Errorf("Caller fields should win over defaults %v", record)
+	}
+
+	// Other tables are unaffected
+	rows, _ = watcher.BatchCreateRows("Results", []map[string]interface{}{{"Name": "Result"}})
+	if record := fake.Record("Results", rows[0].ID); record["Source"] != nil {
+		t.Errorf("Defaults applied to the wrong table %v", record)
+	}
+}
@@ -0,0 +1,101 @@
+package airtablewatcher
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned instead of sending a request while the circuit breaker is open
+var ErrCircuitOpen = errors.New("airtable circuit breaker is open")
+
+// CircuitBreaker stops sending requests to airtable after Threshold consecutive failures.
+// Once Cooldown has passed a single request is let through to test if airtable has recovered,
+// closing the circuit if it succeeds and opening it again if it fails
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	state    string
+	failures int
+	openedAt time.Time
+	// set while the half-open test request is in flight
+	testing bool
+	sync.Mutex
+}
+
+// NewCircuitBreaker Create a circuit breaker that opens after threshold consecutive failures for cooldown
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, state: CircuitClosed}
+}
+
+// State Get the current state, one of CircuitClosed, CircuitOpen or CircuitHalfOpen
+func (c *CircuitBreaker) State() string {
+	c.Lock()
+	defer c.Unlock()
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= c.Cooldown {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+// allow Check if a request may be sent
+func (c *CircuitBreaker) allow() bool {
+	c.Lock()
+	defer c.Unlock()
+	switch c.state {
+	case CircuitOpen:
+		if time.Since(c.openedAt) < c.Cooldown {
+			return false
+		}
+		c.state = CircuitHalfOpen
+		c.testing = true
+		return true
+	case CircuitHalfOpen:
+		// Only one test request at a time
+		if c.testing {
+			return false
+		}
+		c.testing = true
+		return true
+	}
+	return true
+}
+
+// record Record the outcome of a request, returning true if this failure opened the circuit
+func (c *CircuitBreaker) record(success bool) bool {
+	c.Lock()
+	defer c.Unlock()
+	c.testing = false
+	if success {
+		c.state = CircuitClosed
+		c.failures = 0
+		return false
+	}
+
+	c.failures++
+	if c.state == CircuitHalfOpen || (c.state == CircuitClosed && c.failures >= c.Threshold) {
+		c.state = CircuitOpen
+		c.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// release Forget a request without recording its outcome, for requests canceled by their caller that say
+// nothing about airtable's health. A canceled half-open test request lets the next one through
+func (c *CircuitBreaker) release() {
+	c.Lock()
+	defer c.Unlock()
+	c.testing = false
+}
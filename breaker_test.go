@@ -0,0 +1,82 @@
+package airtablewatcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.Breaker = NewCircuitBreaker(2, time.Millisecond*100)
+	var opened error
+	watcher.OnCircuitOpen = func(err error) {
+		opened = err
+	}
+
+	// Two failures open the circuit
+	fake.FailNext(http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE")
+	fake.FailNext(http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE")
+	watcher.GetRows("Tasks")
+	if watcher.Health().CircuitState != CircuitClosed {
+		t.Errorf("Circuit should still be closed after one failure")
+	}
+	watcher.GetRows("Tasks")
+	if watcher.Health().CircuitState != CircuitOpen || opened == nil {
+		t.Errorf("Circuit should be open")
+	}
+
+	// Requests are not sent while open
+	requests := fake.Requests()
+	if _, err := watcher.GetRows("Tasks"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if fake.Requests() != requests {
+		t.Errorf("Request was sent while circuit was open")
+	}
+
+	// After the cooldown a successful request closes it
+	time.Sleep(time.Millisecond * 100)
+	if watcher.Health().CircuitState != CircuitHalfOpen {
+		t.Errorf("Circuit should be half open")
+	}
+	if _, err := watcher.GetRows("Tasks"); err != nil {
+		t.Errorf(err.Error())
+	}
+	if watcher.Health().CircuitState != CircuitClosed {
+		t.Errorf("Circuit should have closed")
+	}
+}
+
+func TestCircuitBreakerIgnoresCanceled(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.Breaker = NewCircuitBreaker(2, time.Hour)
+
+	// Requests canceled by their caller say nothing about airtable
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := watcher.GetRowContext(ctx, "Tasks", id); err == nil {
+			t.Errorf("Expected canceled request to fail")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	watcher.GetRowContext(ctx, "Tasks", id)
+
+	if state := watcher.Health().CircuitState; state != CircuitClosed {
+		t.Errorf("Circuit should still be closed, got %s", state)
+	}
+	if _, err := watcher.GetRow("Tasks", id); err != nil {
+		t.Errorf("Expected healthy request to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package airtablewatcher
+
+// The row cache holds GetRows results for the current poll cycle only.
+// It is reset at the start of every poll and dropped when the poll ends, so a guard or action calling GetRows
+// on a table the poll just fetched doesn't fetch it again. It is off between polls
+
+// cachedRows Get a table's rows for a query key from this cycle's cache
+func (t *Watcher) cachedRows(tableName, key string) ([]Row, bool) {
+	t.Lock()
+	defer t.Unlock()
+	rows, ok := t.rowCache[tableName][key]
+	if !ok {
+		return nil, false
+	}
+	// Copy so callers can sort or append without touching the cache
+	return append([]Row{}, rows...), true
+}
+
+// cacheRows Store a table's rows for a query key in this cycle's cache, if caching is on
+func (t *Watcher) cacheRows(tableName, key string, rows []Row) {
+	t.Lock()
+	defer t.Unlock()
+	if t.rowCache == nil {
+		return
+	}
+	if t.rowCache[tableName] == nil {
+		t.rowCache[tableName] = map[string][]Row{}
+	}
+	t.rowCache[tableName][key] = append([]Row{}, rows...)
+}
+
+// InvalidateTable Drop a table's rows from the poll cycle cache so the next GetRows fetches them again.
+// SetRow does this automatically for the table it writes to
+func (t *Watcher) InvalidateTable(tableName string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.rowCache, tableName)
+}
+
+// setRowCaching Turn the poll cycle cache on with a fresh cache, or off
+func (t *Watcher) setRowCaching(on bool) {
+	t.Lock()
+	defer t.Unlock()
+	if on {
+		t.rowCache = map[string]map[string][]Row{}
+	} else {
+		t.rowCache = nil
+	}
+}
@@ -0,0 +1,63 @@
+package airtablewatcher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRowCache(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	// No caching outside a poll
+	watcher.GetRows("Tasks")
+	requests := fake.ListRequests("Tasks")
+	watcher.GetRows("Tasks")
+	if fake.ListRequests("Tasks") != requests+1 {
+		t.Errorf("Should not cache outside a poll cycle")
+	}
+
+	// A guard reading the table it was triggered from reuses the poll's rows
+	guard := func(ctx context.Context, watcher *Watcher, row *Row) (bool, error) {
+		requests := fake.ListRequests("Tasks")
+		watcher.GetRows("Tasks")
+		if fake.ListRequests("Tasks") != requests {
+			t.Errorf("GetRows was not cached in the poll cycle")
+		}
+
+		// Writes invalidate
+		watcher.SetRow("Tasks", row.ID, map[string]interface{}{"State": "Done"})
+		requests = fake.ListRequests("Tasks")
+		rows, _ := watcher.GetRows("Tasks")
+		if fake.ListRequests("Tasks") != requests+1 || rows[0].GetFieldString("State") != "Done" {
+			t.Errorf("SetRow did not invalidate the cache")
+		}
+		return false, nil
+	}
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {}, WithGuard(guard))
+	watcher.RunOnce(context.Background())
+
+	if fake.Record("Tasks", id)["State"] != "Done" {
+		t.Errorf("Guard did not run")
+	}
+}
+
+func TestRowCacheDroppedBetweenPolls(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "New"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {})
+
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fake.SetField("Tasks", id, "State", "Changed")
+	requests := fake.ListRequests("Tasks")
+	rows, err := watcher.GetRows("Tasks")
+	if err != nil || fake.ListRequests("Tasks") != requests+1 || rows[0].GetFieldString("State") != "Changed" {
+		t.Errorf("GetRows between polls should fetch fresh rows, got %v %v", rows, err)
+	}
+}
@@ -0,0 +1,86 @@
+package airtablewatcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// QueueSetRow Queue a write of fields to a row, to be sent with other queued writes in batches when the poll cycle ends
+// (or on Flush), instead of immediately. Queued writes to the same row are merged, later values winning.
+// Use SetRow for writes that must happen right away
+func (t *Watcher) QueueSetRow(tableName, recordID string, fields map[string]interface{}) error {
+	if t.SkipEmptyWrites {
+		fields = withoutEmpty(fields)
+	}
+	if t.StrictFields {
+		if err := t.checkFields(tableName, fields); err != nil {
+			return err
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.queueWrite(tableName, recordID, fields, true)
+	return nil
+}
+
+// queueWrite Merge fields into a row's pending write, must hold the lock.
+// If overwrite is false values already queued are kept
+func (t *Watcher) queueWrite(tableName, recordID string, fields map[string]interface{}, overwrite bool) {
+	if t.pendingWrites[tableName] == nil {
+		t.pendingWrites[tableName] = map[string]map[string]interface{}{}
+	}
+	pending := t.pendingWrites[tableName][recordID]
+	if pending == nil {
+		pending = map[string]interface{}{}
+		t.pendingWrites[tableName][recordID] = pending
+	}
+	for name, value := range fields {
+		if _, ok := pending[name]; ok && !overwrite {
+			continue
+		}
+		pending[name] = value
+	}
+}
+
+// Flush Send every queued write now, MaxBatchSize rows per request.
+// Writes that fail stay queued for the next flush
+func (t *Watcher) Flush() error {
+	t.Lock()
+	pendingWrites := t.pendingWrites
+	t.pendingWrites = map[string]map[string]map[string]interface{}{}
+	t.Unlock()
+
+	var firstErr error
+	for tableName, rows := range pendingWrites {
+		updates := make([]recordUpdate, 0, len(rows))
+		for recordID, fields := range rows {
+			updates = append(updates, recordUpdate{ID: recordID, Fields: fields})
+		}
+		sort.Slice(updates, func(i, j int) bool { return updates[i].ID < updates[j].ID })
+
+		updated, err := t.updateRecords(context.Background(), tableName, updates)
+		if err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("error flushing writes to %s: %w", tableName, err)
+		}
+		// Put back what wasn't sent, without overwriting anything queued since
+		t.Lock()
+		for _, update := range updates[updated:] {
+			t.queueWrite(tableName, update.ID, update.Fields, false)
+		}
+		t.Unlock()
+	}
+
+	return firstErr
+}
+
+// flushAndLog Flush queued writes, logging any error as an event
+func (t *Watcher) flushAndLog() {
+	if err := t.Flush(); err != nil {
+		t.logEvent(Event{Type: EventError, Detail: err.Error()})
+	}
+}
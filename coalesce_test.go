@@ -0,0 +1,74 @@
+package airtablewatcher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQueueSetRow(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	ids := []string{}
+	for i := 0; i < MaxBatchSize+2; i++ {
+		ids = append(ids, fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"}))
+	}
+	watcher := newFakeWatcher(t, fake)
+
+	for _, id := range ids {
+		watcher.QueueSetRow("Tasks", id, map[string]interface{}{"State": "Queued"})
+		watcher.QueueSetRow("Tasks", id, map[string]interface{}{"State": "Done", "Note": "merged"})
+	}
+	if fake.Record("Tasks", ids[0])["State"] != "ToDo" {
+		t.Errorf("Queued write was sent before flushing")
+	}
+
+	requests := fake.Requests()
+	if err := watcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := fake.Requests() - requests; sent != 2 {
+		t.Errorf("Expected 2 batched requests, got %d", sent)
+	}
+	for _, id := range ids {
+		if record := fake.Record("Tasks", id); record["State"] != "Done" || record["Note"] != "merged" {
+			t.Errorf("Queued writes not merged %v", record)
+		}
+	}
+
+	// Failed writes stay queued
+	watcher.QueueSetRow("Tasks", ids[0], map[string]interface{}{"State": "Retry"})
+	fake.FailNext(403, "INVALID_PERMISSIONS")
+	if err := watcher.Flush(); err == nil {
+		t.Errorf("Expected flush error")
+	}
+	watcher.Flush()
+	if fake.Record("Tasks", ids[0])["State"] != "Retry" {
+		t.Errorf("Failed write was not retried")
+	}
+}
+
+func TestCoalesceWrites(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.ProcessingField = "Processing"
+	watcher.CoalesceWrites = true
+
+	var processing interface{}
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		processing = fake.Record("Tasks", row.ID)["Processing"]
+		watcher.QueueSetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+	})
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The processing mark is visible while the action runs
+	if processing != watcher.WorkerID {
+		t.Errorf("Processing field should show the worker while running, got %v", processing)
+	}
+	// and clearing it is merged into one write with the state
+	if record := fake.Record("Tasks", id); record["State"] != "Done" || record["Processing"] != nil {
+		t.Errorf("Incorrect flushed writes %v", record)
+	}
+}
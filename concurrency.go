@@ -0,0 +1,224 @@
+package airtablewatcher
+
+import (
+	"context"
+	"sync"
+)
+
+// Defaults for the adaptive concurrency controller
+const (
+	DefaultConcurrencyDecreaseFactor = 0.5
+)
+
+// AdaptiveConcurrency limits how many action functions run at once, adjusting the limit AIMD-style.
+//
+// Every successful airtable request raises the limit by 1/limit (so roughly +1 per full window of requests),
+// and every rate limited (429) response multiplies the limit by DecreaseFactor.
+// The limit always stays between Min and Max.
+// Use NewAdaptiveConcurrency, although a struct literal also works with the limit starting at Min
+type AdaptiveConcurrency struct {
+	Min int
+	Max int
+	// Factor the limit is multiplied by when a rate limit is hit, between 0 and 1
+	DecreaseFactor float64
+
+	limit    float64
+	inFlight int
+	// closed and replaced whenever a slot may have become available
+	wait chan struct{}
+	mu   sync.Mutex
+}
+
+// NewAdaptiveConcurrency Create a new adaptive concurrency controller starting at min
+func NewAdaptiveConcurrency(min, max int) *AdaptiveConcurrency {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &AdaptiveConcurrency{
+		Min:            min,
+		Max:            max,
+		DecreaseFactor: DefaultConcurrencyDecreaseFactor,
+		limit:          float64(min),
+		wait:           make(chan struct{}),
+	}
+}
+
+// Limit Get the current concurrency limit
+func (a *AdaptiveConcurrency) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	return int(a.limit)
+}
+
+// InFlight Get the number of slots currently held
+func (a *AdaptiveConcurrency) InFlight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inFlight
+}
+
+// Acquire Block until a slot is available or the context is canceled
+func (a *AdaptiveConcurrency) Acquire(ctx context.Context) error {
+	for {
+		a.mu.Lock()
+		a.init()
+		if a.inFlight < int(a.limit) {
+			a.inFlight++
+			a.mu.Unlock()
+			return nil
+		}
+		wait := a.wait
+		a.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// Release Release a slot obtained with Acquire
+func (a *AdaptiveConcurrency) Release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	if a.inFlight > 0 {
+		a.inFlight--
+	}
+	a.broadcast()
+}
+
+// OnSuccess Additively increase the limit after a successful request
+func (a *AdaptiveConcurrency) OnSuccess() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	a.limit += 1 / a.limit
+	if _, max := a.bounds(); a.limit > max {
+		a.limit = max
+	}
+	a.broadcast()
+}
+
+// OnRateLimit Multiplicatively decrease the limit after a rate limited request
+func (a *AdaptiveConcurrency) OnRateLimit() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.init()
+	factor := a.DecreaseFactor
+	if factor <= 0 || factor >= 1 {
+		factor = DefaultConcurrencyDecreaseFactor
+	}
+	a.limit *= factor
+	if min, _ := a.bounds(); a.limit < min {
+		a.limit = min
+	}
+}
+
+// init Set up a controller built as a struct literal, starting the limit at Min. Must hold the lock
+func (a *AdaptiveConcurrency) init() {
+	if a.wait == nil {
+		a.wait = make(chan struct{})
+	}
+	if min, _ := a.bounds(); a.limit < min {
+		a.limit = min
+	}
+}
+
+// bounds Get the limits of the limit, clamped like NewAdaptiveConcurrency clamps them
+func (a *AdaptiveConcurrency) bounds() (min, max float64) {
+	min, max = float64(a.Min), float64(a.Max)
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// broadcast wakes up everyone waiting on a slot, must hold the lock
+func (a *AdaptiveConcurrency) broadcast() {
+	close(a.wait)
+	a.wait = make(chan struct{})
+}
+
+// semaphore is a fixed size counting semaphore
+type semaphore chan struct{}
+
+// acquire Block until a slot is free or the context is canceled
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release Free a slot obtained with acquire
+func (s semaphore) release() {
+	<-s
+}
+
+// tableSemaphore Get the semaphore limiting a table to TableConcurrency[tableName], nil if it has no limit
+func (t *Watcher) tableSemaphore(tableName string) semaphore {
+	t.Lock()
+	defer t.Unlock()
+	if sem, ok := t.tableSemaphores[tableName]; ok {
+		return sem
+	}
+	limit := t.TableConcurrency[tableName]
+	if limit <= 0 {
+		return nil
+	}
+	sem := make(semaphore, limit)
+	t.tableSemaphores[tableName] = sem
+	return sem
+}
+
+// cancelWatchSlots Get the semaphore limiting cancel checks to CancelWatchConcurrency, nil if there is no limit
+func (t *Watcher) cancelWatchSlots() semaphore {
+	t.Lock()
+	defer t.Unlock()
+	if t.cancelWatchSemaphore == nil && t.CancelWatchConcurrency > 0 {
+		t.cancelWatchSemaphore = make(semaphore, t.CancelWatchConcurrency)
+	}
+	return t.cancelWatchSemaphore
+}
+
+// acquireSlots Wait for a slot from the watch's, the table's and the global limits, in that order.
+// Returns a function releasing all of them
+func (t *Watcher) acquireSlots(ctx context.Context, watcher *watch) (func(), error) {
+	releases := []func(){}
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	for _, sem := range []semaphore{watcher.semaphore, t.tableSemaphore(watcher.tableName)} {
+		if sem == nil {
+			continue
+		}
+		if err := sem.acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, sem.release)
+	}
+	if t.Concurrency != nil {
+		if err := t.Concurrency.Acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, t.Concurrency.Release)
+	}
+
+	return release, nil
+}
@@ -0,0 +1,169 @@
+package airtablewatcher
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	concurrency := NewAdaptiveConcurrency(1, 4)
+	if concurrency.Limit() != 1 {
+		t.Errorf("Should start at min")
+	}
+
+	// Successes should increase the limit up to max
+	for i := 0; i < 100; i++ {
+		concurrency.OnSuccess()
+	}
+	if concurrency.Limit() != 4 {
+		t.Errorf("Should have increased to max, got %d", concurrency.Limit())
+	}
+
+	// Rate limits should back off down to min
+	concurrency.OnRateLimit()
+	if concurrency.Limit() != 2 {
+		t.Errorf("Should have halved, got %d", concurrency.Limit())
+	}
+	concurrency.OnRateLimit()
+	concurrency.OnRateLimit()
+	if concurrency.Limit() != 1 {
+		t.Errorf("Should not go below min, got %d", concurrency.Limit())
+	}
+}
+
+func TestAdaptiveConcurrencyAcquire(t *testing.T) {
+	concurrency := NewAdaptiveConcurrency(1, 1)
+	if err := concurrency.Acquire(context.Background()); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	// Second acquire should block until the context is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if err := concurrency.Acquire(ctx); err == nil {
+		t.Errorf("Should not have acquired a second slot")
+	}
+
+	// After release we should be able to acquire again
+	concurrency.Release()
+	if err := concurrency.Acquire(context.Background()); err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestAdaptiveConcurrencyLiteral(t *testing.T) {
+	// The limit starts and stays at Min, or 1 without one
+	for concurrency, min := range map[*AdaptiveConcurrency]int{{}: 1, {Min: 2, Max: 3}: 2} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if err := concurrency.Acquire(ctx); err != nil {
+			t.Errorf("Literal %+v should start with a slot free: %v", concurrency, err)
+		}
+		cancel()
+		concurrency.OnSuccess()
+		concurrency.Release()
+		concurrency.OnRateLimit()
+		if concurrency.Limit() != min {
+			t.Errorf("Literal %+v should stay at its min, got %d", concurrency, concurrency.Limit())
+		}
+	}
+}
+
+func TestTableConcurrency(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	for i := 0; i < 5; i++ {
+		fake.AddRecord("Busy", map[string]interface{}{"State": "ToDo"})
+	}
+	fake.AddRecord("Urgent", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.TableConcurrency = map[string]int{"Busy": 2}
+
+	release := make(chan struct{})
+	running := make(chan string, 10)
+	action := func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		running <- tableName
+		<-release
+	}
+	watcher.RegisterFunction("Busy", "State", []string{"ToDo"}, action)
+	watcher.RegisterFunction("Urgent", "State", []string{"ToDo"}, action)
+	watcher.pollCycle(context.Background())
+
+	// Busy is capped at 2 and doesn't block Urgent
+	counts := map[string]int{}
+	timeout := time.After(time.Millisecond * 200)
+collect:
+	for {
+		select {
+		case tableName := <-running:
+			counts[tableName]++
+		case <-timeout:
+			break collect
+		}
+	}
+	if counts["Busy"] != 2 || counts["Urgent"] != 1 {
+		t.Errorf("Incorrect concurrency %v", counts)
+	}
+	close(release)
+	watcher.running.Wait()
+}
+
+// inFlightTransport tracks the most single record reads in flight at once
+type inFlightTransport struct {
+	base http.RoundTripper
+	sync.Mutex
+	current, max int
+}
+
+func (i *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.Count(req.URL.Path, "/") < 4 {
+		return i.base.RoundTrip(req)
+	}
+	i.Lock()
+	i.current++
+	if i.current > i.max {
+		i.max = i.current
+	}
+	i.Unlock()
+	time.Sleep(time.Millisecond * 5)
+	defer func() {
+		i.Lock()
+		i.current--
+		i.Unlock()
+	}()
+	return i.base.RoundTrip(req)
+}
+
+func TestCancelWatchConcurrency(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	for i := 0; i < 5; i++ {
+		fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	}
+	watcher := newFakeWatcher(t, fake)
+	transport := &inFlightTransport{base: watcher.Transport}
+	watcher.Transport = transport
+	watcher.PollInterval = time.Millisecond * 10
+	watcher.CancelWatchConcurrency = 1
+
+	release := make(chan struct{})
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		<-release
+	}, "Stop")
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	close(release)
+	watcher.running.Wait()
+
+	transport.Lock()
+	defer transport.Unlock()
+	if transport.max != 1 {
+		t.Errorf("Expected one cancel check at a time, got %d", transport.max)
+	}
+}
@@ -1,14 +1,220 @@
 package airtablewatcher
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configRows Get the rows of the config table, in ConfigViewName if set.
+// With ConfigCacheTTL set they are reused until the TTL passes or the config version changes
+func (t *Watcher) configRows() ([]Row, error) {
+	t.Lock()
+	if t.ConfigCacheTTL > 0 && t.configCache != nil && time.Since(t.configCachedAt) < t.ConfigCacheTTL {
+		rows := append([]Row{}, t.configCache...)
+		t.Unlock()
+		return rows, nil
+	}
+	t.Unlock()
+
+	rows, err := t.GetRowsWithOptions(t.ConfigTableName, ListOptions{View: t.ConfigViewName})
+	if err != nil {
+		return nil, err
+	}
+	if t.ConfigCacheTTL > 0 {
+		t.Lock()
+		t.configCache = append([]Row{}, rows...)
+		t.configCachedAt = time.Now()
+		t.Unlock()
+	}
+	return rows, nil
+}
+
+// InvalidateConfig Drop the cached config so the next read fetches it again, see ConfigCacheTTL
+func (t *Watcher) InvalidateConfig() {
+	t.Lock()
+	defer t.Unlock()
+	t.configCache = nil
+}
+
+// configRow Get the config row for a key, nil if there is none. Always read fresh, never cached
+func (t *Watcher) configRow(ctx context.Context, key string) (*Row, error) {
+	query := ListOptions{FilterByFormula: formulaField("Key") + "=" + formulaString(key), MaxRecords: 1}.query()
+	rows, err := t.listRecords(ctx, t.ConfigTableName, query)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config key %s: %w", key, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// checkConfigVersion Drop the cached config if the ConfigVersionKey row changed since it was last read
+func (t *Watcher) checkConfigVersion(ctx context.Context) error {
+	if t.ConfigVersionKey == "" {
+		return nil
+	}
+	row, err := t.configRow(ctx, t.ConfigVersionKey)
+	if err != nil {
+		return err
+	}
+	version := ""
+	if row != nil {
+		version = row.GetFieldString("Value")
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if version != t.configVersion {
+		t.configVersion = version
+		t.configCache = nil
+	}
+	return nil
+}
+
+// BumpConfigVersion Increment the number in the ConfigVersionKey row, creating it if needed,
+// so every watcher sharing the config table drops its cached config on its next poll.
+// Call it after changing the config table
+func (t *Watcher) BumpConfigVersion() error {
+	if t.ConfigVersionKey == "" {
+		return errors.New("ConfigVersionKey is not set")
+	}
+	ctx := context.Background()
+	row, err := t.configRow(ctx, t.ConfigVersionKey)
+	if err != nil {
+		return err
+	}
+
+	version := "1"
+	if row == nil {
+		_, err = t.createRecords(ctx, t.ConfigTableName, []map[string]interface{}{{"Key": t.ConfigVersionKey, "Value": version}})
+	} else {
+		current, _ := strconv.Atoi(row.GetFieldString("Value"))
+		version = strconv.Itoa(current + 1)
+		err = t.setRow(ctx, t.ConfigTableName, row.ID, map[string]interface{}{"Value": version})
+	}
+	if err != nil {
+		return fmt.Errorf("error bumping config version: %w", err)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.configVersion = version
+	t.configCache = nil
+	return nil
+}
 
 // GetConfig Get value of config key
 func (t *Watcher) GetConfig(key string) (string, error) {
-	rows, err := t.GetRows(t.ConfigTableName)
+	rows, err := t.configRows()
+	if err != nil {
+		return "", err
+	}
+	return configValue(rows, key)
+}
+
+// SetConfig Set the value of an existing config key, returning an error if the key is not in the config table.
+// Keys are looked up in the rows GetConfig reads, ConfigViewName included, and if several rows share the key the
+// first one is updated, the same one GetConfig returns
+func (t *Watcher) SetConfig(key, value string) error {
+	return t.setConfig(key, value, false)
+}
+
+// SetOrCreateConfig Set the value of a config key like SetConfig, adding a Key/Value row if the key is missing.
+// With ConfigViewName set, GetConfig only sees the new row if it matches the view
+func (t *Watcher) SetOrCreateConfig(key, value string) error {
+	return t.setConfig(key, value, true)
+}
+
+// setConfig Write a config value, then drop the cached config and bump ConfigVersionKey so other watchers see it
+func (t *Watcher) setConfig(key, value string, create bool) error {
+	ctx := context.Background()
+	// Read fresh so the row found is the one GetConfig will read next
+	t.InvalidateConfig()
+	t.InvalidateTable(t.ConfigTableName)
+	rows, err := t.configRows()
+	if err != nil {
+		return fmt.Errorf("error reading config key %s: %w", key, err)
+	}
+	var row *Row
+	for i := range rows {
+		if rows[i].GetFieldString("Key") == key {
+			row = &rows[i]
+			break
+		}
+	}
+	switch {
+	case row != nil:
+		err = t.setRow(ctx, t.ConfigTableName, row.ID, map[string]interface{}{"Value": value})
+	case create:
+		_, err = t.createRecords(ctx, t.ConfigTableName, []map[string]interface{}{{"Key": key, "Value": value}})
+	default:
+		return fmt.Errorf("config key %s not found", key)
+	}
+	if err != nil {
+		return fmt.Errorf("error setting config key %s: %w", key, err)
+	}
+
+	t.InvalidateConfig()
+	if t.ConfigVersionKey != "" && key != t.ConfigVersionKey {
+		return t.BumpConfigVersion()
+	}
+	return nil
+}
+
+// GetAllConfig Get every key/value in the config table.
+// If a key appears more than once the first row wins, same as GetConfig
+func (t *Watcher) GetAllConfig() (map[string]string, error) {
+	rows, err := t.configRows()
+	if err != nil {
+		return nil, err
+	}
+	return configValues(rows), nil
+}
+
+// GetConfigFromTable Get the value of a key in a Key/Value table other than ConfigTableName, e.g. a module's own config.
+// ConfigViewName and ConfigCacheTTL only apply to ConfigTableName
+func (t *Watcher) GetConfigFromTable(tableName, key string) (string, error) {
+	rows, err := t.GetRows(tableName)
 	if err != nil {
 		return "", err
 	}
+	return configValue(rows, key)
+}
 
+// GetAllConfigFrom Get every key/value in a Key/Value table like GetAllConfig, for tables other than ConfigTableName
+func (t *Watcher) GetAllConfigFrom(tableName string) (map[string]string, error) {
+	rows, err := t.GetRows(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return configValues(rows), nil
+}
+
+// GetAllConfigFromTables Read several Key/Value tables at once into one map with keys namespaced by table,
+// e.g. "Billing.Enabled" for the Enabled key of the Billing table
+func (t *Watcher) GetAllConfigFromTables(tableNames []string) (map[string]string, error) {
+	allRows, err := t.GetRowsMulti(tableNames)
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]string{}
+	for _, tableName := range tableNames {
+		for key, value := range configValues(allRows[tableName]) {
+			config[tableName+"."+key] = value
+		}
+	}
+	return config, nil
+}
+
+// configValue Get the value of the first row with key
+func configValue(rows []Row, key string) (string, error) {
 	for _, row := range rows {
 		if thisKey := row.GetFieldString("Key"); thisKey == key {
 			return row.GetFieldString("Value"), nil
@@ -17,3 +223,122 @@ func (t *Watcher) GetConfig(key string) (string, error) {
 
 	return "", errors.New("config key not found")
 }
+
+// configValues Get every key/value of config rows, the first row winning for repeated keys
+func configValues(rows []Row) map[string]string {
+	config := map[string]string{}
+	for _, row := range rows {
+		key := row.GetFieldString("Key")
+		if _, ok := config[key]; ok || key == "" {
+			continue
+		}
+		config[key] = row.GetFieldString("Value")
+	}
+
+	return config
+}
+
+// LoadConfig Read the config table once into the fields of a struct tagged with their keys, e.g.
+//
+//	type Config struct {
+//		MaxRetries int           `config:"MaxRetries"`
+//		Enabled    bool          `config:"Enabled,required"`
+//		Timeout    time.Duration `config:"Timeout"`
+//	}
+//
+// Values are converted to string, bool, int, uint, float and time.Duration fields, and comma separated for []string.
+// Missing keys leave the field as it was unless the tag is marked required
+func (t *Watcher) LoadConfig(v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("LoadConfig needs a pointer to a struct")
+	}
+
+	config, err := t.GetAllConfig()
+	if err != nil {
+		return err
+	}
+
+	structValue := value.Elem()
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag, ok := field.Tag.Lookup("config")
+		if !ok || field.PkgPath != "" {
+			continue
+		}
+		key := strings.Split(tag, ",")[0]
+		if key == "" {
+			key = field.Name
+		}
+
+		configValue, ok := config[key]
+		if !ok {
+			if tagHasOption(tag, "required") {
+				return fmt.Errorf("config key %s is required", key)
+			}
+			continue
+		}
+		if err := setConfigField(structValue.Field(i), configValue); err != nil {
+			return fmt.Errorf("config key %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// setConfigField Convert a config value to the type of a struct field and set it
+func setConfigField(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported field type %s", field.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items).Convert(field.Type()))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
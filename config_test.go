@@ -0,0 +1,209 @@
+package airtablewatcher
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	for key, value := range map[string]string{"MaxRetries": "3", "Enabled": "true", "Timeout": "90s", "Ratio": "0.5", "Tables": "Tasks, Jobs", "Name": "worker"} {
+		fake.AddRecord("Config", map[string]interface{}{"Key": key, "Value": value})
+	}
+	watcher := newFakeWatcher(t, fake)
+
+	config := struct {
+		MaxRetries int           `config:"MaxRetries"`
+		Enabled    bool          `config:"Enabled,required"`
+		Timeout    time.Duration `config:"Timeout"`
+		Ratio      float64       `config:"Ratio"`
+		Tables     []string      `config:"Tables"`
+		Name       string        `config:""`
+		Missing    string        `config:"Missing"`
+		Untagged   string
+	}{Missing: "default"}
+	if err := watcher.LoadConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxRetries != 3 || !config.Enabled || config.Timeout != time.Second*90 || config.Ratio != 0.5 || config.Name != "worker" {
+		t.Errorf("Incorrect config %+v", config)
+	}
+	if !reflect.DeepEqual(config.Tables, []string{"Tasks", "Jobs"}) {
+		t.Errorf("Incorrect list %v", config.Tables)
+	}
+	if config.Missing != "default" {
+		t.Errorf("Missing optional key changed the field")
+	}
+
+	required := struct {
+		Missing string `config:"Missing,required"`
+	}{}
+	if err := watcher.LoadConfig(&required); err == nil {
+		t.Errorf("Expected error for missing required key")
+	}
+	invalid := struct {
+		Name int `config:"Name"`
+	}{}
+	if err := watcher.LoadConfig(&invalid); err == nil {
+		t.Errorf("Expected error for a value of the wrong type")
+	}
+}
+
+func TestConfigViewName(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Config", map[string]interface{}{"Key": "Shared", "Value": "other team"})
+	fake.AddRecord("Config", map[string]interface{}{"Key": "Enabled", "Value": "true", "Owner": "watcher"})
+	if err := fake.AddView("Config", "WatcherConfig", "{Owner} = 'watcher'"); err != nil {
+		t.Fatal(err)
+	}
+	watcher := newFakeWatcher(t, fake)
+	watcher.ConfigViewName = "WatcherConfig"
+
+	config, err := watcher.GetAllConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, map[string]string{"Enabled": "true"}) {
+		t.Errorf("Expected only the view's config, got %v", config)
+	}
+	if _, err := watcher.GetConfig("Shared"); err == nil {
+		t.Errorf("Expected key outside the view to be missing")
+	}
+}
+
+func TestConfigVersionKey(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	enabled := fake.AddRecord("Config", map[string]interface{}{"Key": "Enabled", "Value": "true"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.ConfigCacheTTL = time.Hour
+	watcher.ConfigVersionKey = "ConfigVersion"
+	other := newFakeWatcher(t, fake)
+	other.ConfigVersionKey = "ConfigVersion"
+
+	if value, _ := watcher.GetConfig("Enabled"); value != "true" {
+		t.Fatalf("Incorrect value %s", value)
+	}
+	fake.SetField("Config", enabled, "Value", "false")
+	if value, _ := watcher.GetConfig("Enabled"); value != "true" {
+		t.Errorf("Expected cached value, got %s", value)
+	}
+
+	// Another watcher bumps the version, the next poll picks it up
+	if err := other.BumpConfigVersion(); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := watcher.GetConfig("Enabled"); value != "false" {
+		t.Errorf("Expected new value after version bump, got %s", value)
+	}
+
+	// Bumping again increments the same row
+	if err := other.BumpConfigVersion(); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := other.GetConfig("ConfigVersion"); version != "2" {
+		t.Errorf("Incorrect version %s", version)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	first := fake.AddRecord("Config", map[string]interface{}{"Key": "Enabled", "Value": "true"})
+	second := fake.AddRecord("Config", map[string]interface{}{"Key": "Enabled", "Value": "true"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.ConfigCacheTTL = time.Hour
+	watcher.ConfigVersionKey = "ConfigVersion"
+
+	if value, _ := watcher.GetConfig("Enabled"); value != "true" {
+		t.Fatalf("Incorrect value %s", value)
+	}
+	if err := watcher.SetConfig("Enabled", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := watcher.GetConfig("Enabled"); value != "false" {
+		t.Errorf("Expected the new value despite the cache, got %s", value)
+	}
+	if fake.Record("Config", first)["Value"] != "false" || fake.Record("Config", second)["Value"] != "true" {
+		t.Errorf("Only the first matching row should be updated")
+	}
+	if version, _ := watcher.GetConfig("ConfigVersion"); version != "1" {
+		t.Errorf("Expected the config version to be bumped, got %s", version)
+	}
+
+	if err := watcher.SetConfig("Missing", "1"); err == nil || err.Error() != "config key Missing not found" {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if err := watcher.SetOrCreateConfig("Missing", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := watcher.GetConfig("Missing"); err != nil || value != "1" {
+		t.Errorf("Expected the key to be created, got %s %v", value, err)
+	}
+}
+
+func TestConfigFromTables(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Config", map[string]interface{}{"Key": "Enabled", "Value": "true"})
+	fake.AddRecord("Billing", map[string]interface{}{"Key": "Enabled", "Value": "false"})
+	fake.AddRecord("Billing", map[string]interface{}{"Key": "Enabled", "Value": "true"})
+	fake.AddRecord("Billing", map[string]interface{}{"Key": "Currency", "Value": "USD"})
+	fake.AddRecord("Email", map[string]interface{}{"Key": "From", "Value": "bot@example.com"})
+	watcher := newFakeWatcher(t, fake)
+
+	if value, err := watcher.GetConfigFromTable("Billing", "Enabled"); err != nil || value != "false" {
+		t.Errorf("Expected the first Billing row, got %s %v", value, err)
+	}
+	if _, err := watcher.GetConfigFromTable("Email", "Enabled"); err == nil {
+		t.Errorf("Expected error for a key in another table")
+	}
+	if config, err := watcher.GetAllConfigFrom("Billing"); err != nil || !reflect.DeepEqual(config, map[string]string{"Enabled": "false", "Currency": "USD"}) {
+		t.Errorf("Incorrect Billing config %v %v", config, err)
+	}
+
+	config, err := watcher.GetAllConfigFromTables([]string{"Billing", "Email"})
+	expected := map[string]string{"Billing.Enabled": "false", "Billing.Currency": "USD", "Email.From": "bot@example.com"}
+	if err != nil || !reflect.DeepEqual(config, expected) {
+		t.Errorf("Incorrect namespaced config %v %v", config, err)
+	}
+	if value, _ := watcher.GetConfig("Enabled"); value != "true" {
+		t.Errorf("ConfigTableName should be unaffected, got %s", value)
+	}
+}
+
+func TestSetConfigViewName(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	shared := fake.AddRecord("Config", map[string]interface{}{"Key": "Enabled", "Value": "true"})
+	own := fake.AddRecord("Config", map[string]interface{}{"Key": "Enabled", "Value": "true", "Owner": "watcher"})
+	fake.AddRecord("Config", map[string]interface{}{"Key": "Shared", "Value": "other team"})
+	if err := fake.AddView("Config", "WatcherConfig", "{Owner} = 'watcher'"); err != nil {
+		t.Fatal(err)
+	}
+	watcher := newFakeWatcher(t, fake)
+	watcher.ConfigViewName = "WatcherConfig"
+
+	// The row in the view is updated, even though another row comes first in the table
+	if err := watcher.SetConfig("Enabled", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Record("Config", own)["Value"] != "false" || fake.Record("Config", shared)["Value"] != "true" {
+		t.Errorf("Expected only the row in the view to be updated")
+	}
+	if value, _ := watcher.GetConfig("Enabled"); value != "false" {
+		t.Errorf("Expected GetConfig to read the new value, got %s", value)
+	}
+
+	// Keys outside the view don't exist for SetConfig either
+	if err := watcher.SetConfig("Shared", "mine"); err == nil {
+		t.Errorf("Expected error for a key outside the view")
+	}
+}
@@ -0,0 +1,139 @@
+package airtablewatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// readAfterWriteInterval is how long GetRowAfterWrite waits between reads
+const readAfterWriteInterval = time.Millisecond * 100
+
+// ErrStaleRead is returned by GetRowAfterWrite when the row never showed the written fields
+var ErrStaleRead = errors.New("row does not reflect the write yet")
+
+// GetRowAfterWrite Read a row, retrying until it shows the fields just written with SetRow or timeout passes.
+// Airtable is eventually consistent, so a read straight after a write can still return the old values.
+// This trades extra requests and latency for a row that is known to be current, so only use it where a stale
+// read would cause a wrong decision. A nil field value waits for the field to be cleared.
+// time.Time values match to the millisecond, or by UTC day in date fields. Attachments match by count and then
+// by ID, or filename for new uploads, since airtable rehosts them under new URLs.
+// On timeout the last row read is returned along with ErrStaleRead
+func (t *Watcher) GetRowAfterWrite(ctx context.Context, tableName, recordID string, fields map[string]interface{}, timeout time.Duration) (*Row, error) {
+	expected := map[string]fieldMatcher{}
+	for field, value := range fields {
+		matcher, err := matchFieldValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding field %s: %w", field, err)
+		}
+		expected[field] = matcher
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		row, err := t.GetRowContext(ctx, tableName, recordID)
+		if err != nil {
+			return nil, err
+		}
+		if rowReflects(row, expected) {
+			return row, nil
+		}
+		if time.Now().Add(readAfterWriteInterval).After(deadline) {
+			return row, fmt.Errorf("%w after %s: %s", ErrStaleRead, timeout, recordID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return row, ctx.Err()
+		case <-time.After(readAfterWriteInterval):
+		}
+	}
+}
+
+// fieldMatcher reports if a re-read row's field reflects a written value
+type fieldMatcher func(row *Row, fieldName string) bool
+
+// rowReflects Check if the row's fields match the expected values
+func rowReflects(row *Row, expected map[string]fieldMatcher) bool {
+	for field, matches := range expected {
+		if !matches(row, field) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchFieldValue Get a matcher for a written field value.
+// Times and attachments come back from airtable in a different shape, everything else is compared as JSON
+func matchFieldValue(value interface{}) (fieldMatcher, error) {
+	switch value := value.(type) {
+	case time.Time:
+		return matchTime(value), nil
+	case AirtableAttachments:
+		return matchAttachments(value), nil
+	case []AirtableAttachment:
+		return matchAttachments(value), nil
+	case []AttachmentUpload:
+		attachments := make([]AirtableAttachment, 0, len(value))
+		for _, upload := range value {
+			attachments = append(attachments, AirtableAttachment{URL: upload.URL, Filename: upload.Filename})
+		}
+		return matchAttachments(attachments), nil
+	}
+
+	encoded, err := encodeFieldValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return func(row *Row, fieldName string) bool {
+		read, _ := encodeFieldValue(row.GetField(fieldName))
+		return read == encoded
+	}, nil
+}
+
+// matchTime Match a written time, which airtable stores to the millisecond in UTC, or as the UTC day in date fields
+func matchTime(written time.Time) fieldMatcher {
+	written = written.UTC().Truncate(time.Millisecond)
+	return func(row *Row, fieldName string) bool {
+		if _, err := time.Parse(AirtableDayFormat, row.GetFieldString(fieldName)); err == nil {
+			return row.GetFieldString(fieldName) == written.Format(AirtableDayFormat)
+		}
+		return row.GetFieldTime(fieldName).Equal(written)
+	}
+}
+
+// matchAttachments Match written attachments, which airtable gives new IDs and URLs when uploaded.
+// Attachments must be in the same order, kept ones must keep their ID and new ones their filename if given
+func matchAttachments(written []AirtableAttachment) fieldMatcher {
+	return func(row *Row, fieldName string) bool {
+		read, err := row.GetFieldAttachments(fieldName)
+		if err != nil || len(read) != len(written) {
+			return false
+		}
+		for i, attachment := range written {
+			if attachment.ID != "" && read[i].ID != attachment.ID {
+				return false
+			}
+			if attachment.ID == "" && attachment.Filename != "" && read[i].Filename != attachment.Filename {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// encodeFieldValue Encode a field value as JSON for comparison.
+// Airtable leaves empty fields out, so empty strings and lists encode the same as nil
+func encodeFieldValue(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	switch string(encoded) {
+	case `""`, "[]":
+		return "null", nil
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,101 @@
+package airtablewatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetRowAfterWrite(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "Count": float64(1), "Notes": "old"})
+	watcher := newFakeWatcher(t, fake)
+	written := map[string]interface{}{"State": "Done", "Count": 2, "Notes": ""}
+
+	// The write shows up after a couple of reads
+	go func() {
+		time.Sleep(readAfterWriteInterval * 2)
+		fake.SetField("Tasks", id, "State", "Done")
+		fake.SetField("Tasks", id, "Count", float64(2))
+		fake.SetField("Tasks", id, "Notes", nil)
+	}()
+	row, err := watcher.GetRowAfterWrite(context.Background(), "Tasks", id, written, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.GetFieldString("State") != "Done" {
+		t.Errorf("Incorrect row %v", row.Fields)
+	}
+
+	// A write that never shows up times out with the last row read
+	row, err = watcher.GetRowAfterWrite(context.Background(), "Tasks", id, map[string]interface{}{"State": "Never"}, readAfterWriteInterval*2)
+	if !errors.Is(err, ErrStaleRead) {
+		t.Errorf("Expected stale read, got %v", err)
+	}
+	if row == nil || row.GetFieldString("State") != "Done" {
+		t.Errorf("Expected the last row read, got %v", row)
+	}
+}
+
+func TestGetRowAfterWriteAttachments(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	kept := map[string]interface{}{"id": "attAAAAAAAAAAAAAA", "url": "https://dl.airtable.com/old.pdf", "filename": "old.pdf"}
+	id := fake.AddRecord("Tasks", map[string]interface{}{"Files": []interface{}{kept}})
+	watcher := newFakeWatcher(t, fake)
+	written := map[string]interface{}{"Files": AirtableAttachments{
+		{ID: "attAAAAAAAAAAAAAA"},
+		{URL: "https://example.com/new.pdf", Filename: "new.pdf"},
+	}}
+
+	// airtable rehosts the upload under its own ID and URL, with extra fields
+	go func() {
+		time.Sleep(readAfterWriteInterval * 2)
+		fake.SetField("Tasks", id, "Files", []interface{}{kept, map[string]interface{}{
+			"id": "attBBBBBBBBBBBBBB", "url": "https://dl.airtable.com/new.pdf", "filename": "new.pdf", "size": 1024, "type": "application/pdf",
+		}})
+	}()
+	if _, err := watcher.GetRowAfterWrite(context.Background(), "Tasks", id, written, time.Second); err != nil {
+		t.Errorf("Expected uploaded attachment to match, got %v", err)
+	}
+
+	// Uploads written as AttachmentUpload match the same way
+	uploads := map[string]interface{}{"Files": []AttachmentUpload{{URL: "https://example.com/old.pdf"}, {URL: "https://example.com/new.pdf", Filename: "new.pdf"}}}
+	if _, err := watcher.GetRowAfterWrite(context.Background(), "Tasks", id, uploads, time.Second); err != nil {
+		t.Errorf("Expected uploads to match, got %v", err)
+	}
+
+	// A different filename or count is still stale
+	for _, attachments := range []AirtableAttachments{{{ID: "attAAAAAAAAAAAAAA"}, {Filename: "other.pdf"}}, {{ID: "attAAAAAAAAAAAAAA"}}} {
+		_, err := watcher.GetRowAfterWrite(context.Background(), "Tasks", id, map[string]interface{}{"Files": attachments}, readAfterWriteInterval*2)
+		if !errors.Is(err, ErrStaleRead) {
+			t.Errorf("Expected stale read for %v, got %v", attachments, err)
+		}
+	}
+}
+
+func TestGetRowAfterWriteTime(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{})
+	watcher := newFakeWatcher(t, fake)
+	written := time.Date(2024, 1, 2, 22, 4, 5, 123456789, time.FixedZone("EST", -5*60*60))
+
+	// Date and time fields come back in UTC to the millisecond, date fields as the UTC day
+	go func() {
+		time.Sleep(readAfterWriteInterval * 2)
+		fake.SetField("Tasks", id, "Due", "2024-01-03T03:04:05.123Z")
+		fake.SetField("Tasks", id, "Day", "2024-01-03")
+	}()
+	fields := map[string]interface{}{"Due": written, "Day": written}
+	if _, err := watcher.GetRowAfterWrite(context.Background(), "Tasks", id, fields, time.Second); err != nil {
+		t.Errorf("Expected times to match, got %v", err)
+	}
+
+	_, err := watcher.GetRowAfterWrite(context.Background(), "Tasks", id, map[string]interface{}{"Due": written.Add(time.Second)}, readAfterWriteInterval*2)
+	if !errors.Is(err, ErrStaleRead) {
+		t.Errorf("Expected stale read for a different time, got %v", err)
+	}
+}
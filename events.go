@@ -0,0 +1,82 @@
+package airtablewatcher
+
+import "time"
+
+// Defaults for the event log
+const (
+	DefaultEventLogSize = 1000
+)
+
+// Event types
+const (
+	// A row matched a watcher's trigger
+	EventMatched = "matched"
+	// A matching row was skipped because it was already running or cooling down
+	EventDeduped = "deduped"
+	// A matching row was held back this cycle, e.g. because it was edited too recently
+	EventSkipped = "skipped"
+	// An action function started on a row
+	EventStarted = "started"
+	// An action function returned normally
+	EventFinished = "finished"
+	// An action function returned after its context was canceled
+	EventCanceled = "canceled"
+	// An action ran longer than Watcher.MaxActionDuration and was canceled
+	EventTimedOut = "timed_out"
+	// A poll cycle failed
+	EventError = "error"
+	// An action returned but its row was still on the value that triggered it, see WithVerifyTransition
+	EventNotTransitioned = "not_transitioned"
+	// This watcher became the leader, see Watcher.Leader
+	EventLeaderAcquired = "leader_acquired"
+	// This watcher stopped being the leader
+	EventLeaderLost = "leader_lost"
+)
+
+// Event is a single entry in the watcher's event log
+type Event struct {
+	Time      time.Time
+	Type      string
+	TableName string
+	RecordID  string
+	// Extra information, e.g. why a row was deduped or the error
+	Detail string
+	// How long the action ran, for finished and canceled events
+	Duration time.Duration
+}
+
+// RecentEvents Get up to the n most recent events, oldest first.
+// n <= 0 returns every event still in the log
+func (t *Watcher) RecentEvents(n int) []Event {
+	t.Lock()
+	defer t.Unlock()
+	if n <= 0 || n > len(t.events) {
+		n = len(t.events)
+	}
+	events := make([]Event, n)
+	copy(events, t.events[len(t.events)-n:])
+	return events
+}
+
+// logEvent Add an event to the log, dropping the oldest once EventLogSize is reached, and pass it to OnEvent
+func (t *Watcher) logEvent(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	t.Lock()
+	t.countEvent(event)
+	size := t.EventLogSize
+	if size > 0 {
+		t.events = append(t.events, event)
+		if len(t.events) > size {
+			t.events = t.events[len(t.events)-size:]
+		}
+	}
+	onEvent := t.OnEvent
+	t.Unlock()
+
+	if onEvent != nil {
+		onEvent(event)
+	}
+}
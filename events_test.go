@@ -0,0 +1,46 @@
+package airtablewatcher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecentEvents(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	release := make(chan struct{})
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		<-release
+	})
+
+	// First poll starts the action, second sees it is still running
+	watcher.pollCycle(context.Background())
+	watcher.pollCycle(context.Background())
+	close(release)
+	watcher.running.Wait()
+
+	types := []string{}
+	for _, event := range watcher.RecentEvents(0) {
+		types = append(types, event.Type)
+	}
+	counts := map[string]int{}
+	for _, eventType := range types {
+		counts[eventType]++
+	}
+	if counts[EventMatched] != 2 || counts[EventDeduped] != 1 || counts[EventStarted] != 1 || counts[EventFinished] != 1 {
+		t.Errorf("Incorrect events %v", types)
+	}
+	if len(watcher.RecentEvents(2)) != 2 {
+		t.Errorf("Expected 2 most recent events")
+	}
+
+	// Size cap
+	watcher.EventLogSize = 3
+	watcher.logEvent(Event{Type: EventError})
+	if len(watcher.RecentEvents(0)) != 3 {
+		t.Errorf("Event log not capped")
+	}
+}
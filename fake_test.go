@@ -0,0 +1,27 @@
+package airtablewatcher
+
+import (
+	"testing"
+
+	"github.com/vertoforce/airtablewatcher/internal/fakeairtable"
+)
+
+const (
+	testAirtableKey  = "keyTESTTESTTESTTE"
+	testAirtableBase = "appTESTTESTTESTTE"
+)
+
+// newFakeAirtable starts an in-memory airtable for tests that don't need a live base
+func newFakeAirtable() *fakeairtable.Server {
+	return fakeairtable.New()
+}
+
+// newFakeWatcher creates a watcher talking to the fake airtable
+func newFakeWatcher(t *testing.T, fake *fakeairtable.Server) *Watcher {
+	watcher, err := NewWatcher(testAirtableKey, testAirtableBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Transport = fake.Transport()
+	return watcher
+}
@@ -0,0 +1,65 @@
+package airtablewatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fabioberger/airtable-go"
+)
+
+// formulaString Quote a value as a string literal for use in a formula
+func formulaString(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `'`, `\'`, -1)
+	return "'" + value + "'"
+}
+
+// formulaField Reference a field by name in a formula
+func formulaField(fieldName string) string {
+	return "{" + fieldName + "}"
+}
+
+// ErrInvalidFormula matches a *FormulaError with errors.Is
+var ErrInvalidFormula = errors.New("invalid formula")
+
+// FormulaError is returned when airtable rejects a formula
+type FormulaError struct {
+	Formula string
+	// The airtable.Error explaining why
+	Err error
+}
+
+// Error implements error
+func (e *FormulaError) Error() string {
+	return fmt.Sprintf("invalid formula %q: %s", e.Formula, e.Err)
+}
+
+// Unwrap returns the airtable error
+func (e *FormulaError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrInvalidFormula) match
+func (e *FormulaError) Is(target error) bool {
+	return target == ErrInvalidFormula
+}
+
+// ValidateFormula Check airtable accepts a filterByFormula for a table, with a cheap fetch of at most one row.
+// Returns a *FormulaError if the formula is rejected, other errors mean the check itself failed
+func (t *Watcher) ValidateFormula(tableName, formula string) error {
+	query := ListOptions{FilterByFormula: formula, MaxRecords: 1}.query()
+	query.Set("pageSize", "1")
+	_, err := t.listPage(context.Background(), tableName, query, "")
+	if err == nil {
+		return nil
+	}
+
+	apiErr := airtable.Error{}
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity && strings.Contains(apiErr.Type, "FORMULA") {
+		return &FormulaError{Formula: formula, Err: apiErr}
+	}
+	return err
+}
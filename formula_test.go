@@ -0,0 +1,33 @@
+package airtablewatcher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormulaString(t *testing.T) {
+	if quoted := formulaString(`it's a \ test`); quoted != `'it\'s a \\ test'` {
+		t.Errorf("Incorrect quoting %s", quoted)
+	}
+}
+
+func TestValidateFormula(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	if err := watcher.ValidateFormula("Tasks", "AND({State} = 'ToDo', NOT({Done}))"); err != nil {
+		t.Errorf("Valid formula rejected: %s", err)
+	}
+	err := watcher.ValidateFormula("Tasks", "{State} = ")
+	if !errors.Is(err, ErrInvalidFormula) {
+		t.Errorf("Expected ErrInvalidFormula, got %v", err)
+	}
+
+	// Failures unrelated to the formula are passed through
+	fake.FailNext(500, "SERVER_ERROR")
+	if err := watcher.ValidateFormula("Tasks", "{State} = 'ToDo'"); err == nil || errors.Is(err, ErrInvalidFormula) {
+		t.Errorf("Expected a plain error, got %v", err)
+	}
+}
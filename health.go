@@ -0,0 +1,32 @@
+package airtablewatcher
+
+import "time"
+
+// Health is a snapshot of the watcher's state
+type Health struct {
+	// Circuit breaker state, CircuitClosed if there is no breaker
+	CircuitState string
+	// Number of action functions running
+	InFlight int
+	// Number of completed poll cycles
+	Polls int
+	// When the last poll cycle started and how long it took, zero before the first poll
+	LastPollStarted  time.Time
+	LastPollDuration time.Duration
+}
+
+// Health Get a snapshot of the watcher's state
+func (t *Watcher) Health() Health {
+	health := Health{CircuitState: CircuitClosed}
+	if t.Breaker != nil {
+		health.CircuitState = t.Breaker.State()
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	health.InFlight = t.inFlight
+	health.Polls = t.polls
+	health.LastPollStarted = t.lastPollStarted
+	health.LastPollDuration = t.lastPollDuration
+	return health
+}
@@ -0,0 +1,37 @@
+package airtablewatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPollTiming(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, recordRuns(make(chan string, 1)))
+
+	lagged := time.Duration(0)
+	watcher.OnPollLag = func(duration time.Duration) {
+		lagged = duration
+	}
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	health := watcher.Health()
+	if health.LastPollDuration <= 0 || health.LastPollStarted.IsZero() {
+		t.Errorf("Poll timing not recorded %+v", health)
+	}
+	if lagged != 0 {
+		t.Errorf("OnPollLag called for a fast poll")
+	}
+
+	// Any real poll takes longer than this
+	watcher.PollInterval = time.Nanosecond
+	watcher.pollCycle(context.Background())
+	if lagged <= 0 {
+		t.Errorf("OnPollLag not called for a slow poll")
+	}
+}
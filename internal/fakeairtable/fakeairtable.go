@@ -0,0 +1,482 @@
+// Package fakeairtable is an in-memory airtable REST API used to test without a live base
+package fakeairtable
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPageSize is the number of records per list page, same as airtable
+const DefaultPageSize = 100
+
+// Server is an in-memory airtable served over HTTP
+type Server struct {
+	*httptest.Server
+	// Records per list page
+	PageSize int
+	// records by table name, each record is {"id", "createdTime", "fields"}
+	tables   map[string][]map[string]interface{}
+	nextID   int
+	requests int
+	// list requests by table name
+	listRequests map[string]int
+	// failures to return for the next requests, in order
+	failures []failure
+	// body of the last request
+	lastBody map[string]interface{}
+	// tables served by the metadata API
+	schema []interface{}
+	// bases served by the metadata API
+	bases []interface{}
+	// registered webhook notification URLs by ID
+	webhooks map[string]string
+	// view filters by table name then view name
+	views map[string]map[string]formula
+	sync.Mutex
+}
+
+type failure struct {
+	statusCode int
+	errorType  string
+	// requests to let through before failing
+	after int
+}
+
+// New Start a new fake airtable server, Close it when done
+func New() *Server {
+	server := &Server{PageSize: DefaultPageSize, tables: map[string][]map[string]interface{}{}, listRequests: map[string]int{}, webhooks: map[string]string{}, views: map[string]map[string]formula{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+// Transport Get a transport that sends every request to this server instead of airtable
+func (s *Server) Transport() http.RoundTripper {
+	target, _ := url.Parse(s.URL)
+	return &redirectTransport{target: target}
+}
+
+// AddTableSchema Add a table to the metadata API, e.g. {"id": "tbl...", "name": "Tasks", "fields": [...]}
+func (s *Server) AddTableSchema(table map[string]interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	s.schema = append(s.schema, table)
+}
+
+// AddBase Add a base to the metadata API's list of bases
+func (s *Server) AddBase(baseID, permissionLevel string) {
+	s.Lock()
+	defer s.Unlock()
+	s.bases = append(s.bases, map[string]interface{}{"id": baseID, "name": baseID, "permissionLevel": permissionLevel})
+}
+
+// AddView Add a view to a table showing only the records matching a formula (see filterByFormula)
+func (s *Server) AddView(tableName, viewName, viewFormula string) error {
+	filter, err := parseFormula(viewFormula)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.views[tableName] == nil {
+		s.views[tableName] = map[string]formula{}
+	}
+	s.views[tableName][viewName] = filter
+	return nil
+}
+
+// AddRecord Add a record to a table, returning its ID
+func (s *Server) AddRecord(tableName string, fields map[string]interface{}) string {
+	s.Lock()
+	defer s.Unlock()
+	return s.add(tableName, fields)["id"].(string)
+}
+
+// SetField Change a single field on a record
+func (s *Server) SetField(tableName, recordID, fieldName string, value interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	if record := s.find(tableName, recordID); record != nil {
+		record["fields"].(map[string]interface{})[fieldName] = value
+	}
+}
+
+// DeleteRecord Remove a record from a table
+func (s *Server) DeleteRecord(tableName, recordID string) {
+	s.Lock()
+	defer s.Unlock()
+	s.remove(tableName, recordID)
+}
+
+// Record Get a copy of a record's fields, nil if it does not exist
+func (s *Server) Record(tableName, recordID string) map[string]interface{} {
+	s.Lock()
+	defer s.Unlock()
+	record := s.find(tableName, recordID)
+	if record == nil {
+		return nil
+	}
+	fields := map[string]interface{}{}
+	for key, value := range record["fields"].(map[string]interface{}) {
+		fields[key] = value
+	}
+	return fields
+}
+
+// RecordIDs Get the IDs of every record in a table, in order
+func (s *Server) RecordIDs(tableName string) []string {
+	s.Lock()
+	defer s.Unlock()
+	ids := []string{}
+	for _, record := range s.tables[tableName] {
+		ids = append(ids, record["id"].(string))
+	}
+	return ids
+}
+
+// FailNext Make the next request fail with the given status and error type
+func (s *Server) FailNext(statusCode int, errorType string) {
+	s.Lock()
+	defer s.Unlock()
+	s.failures = append(s.failures, failure{statusCode, errorType, 0})
+}
+
+// FailAfter Let the next n requests through, then fail one with the given status and error type
+func (s *Server) FailAfter(n int, statusCode int, errorType string) {
+	s.Lock()
+	defer s.Unlock()
+	s.failures = append(s.failures, failure{statusCode, errorType, n})
+}
+
+// LastBody Get the decoded body of the last request
+func (s *Server) LastBody() map[string]interface{} {
+	s.Lock()
+	defer s.Unlock()
+	return s.lastBody
+}
+
+// Requests Get the number of requests served
+func (s *Server) Requests() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.requests
+}
+
+// SetCommentCount Set the number of comments on a record, returned when requested with recordMetadata
+func (s *Server) SetCommentCount(tableName, recordID string, count int) {
+	s.Lock()
+	defer s.Unlock()
+	if record := s.find(tableName, recordID); record != nil {
+		record["commentCount"] = count
+	}
+}
+
+// WebhookSecret is the MAC secret of every webhook the fake registers
+var WebhookSecret = []byte("fake webhook secret")
+
+// Webhooks Get the notification URLs of registered webhooks by webhook ID
+func (s *Server) Webhooks() map[string]string {
+	s.Lock()
+	defer s.Unlock()
+	webhooks := map[string]string{}
+	for id, notificationURL := range s.webhooks {
+		webhooks[id] = notificationURL
+	}
+	return webhooks
+}
+
+// ListRequests Get the number of times a table's records were listed
+func (s *Server) ListRequests(tableName string) int {
+	s.Lock()
+	defer s.Unlock()
+	return s.listRequests[tableName]
+}
+
+// list a page of records in view (nil for all), must hold the lock
+func (s *Server) list(tableName string, query url.Values, filter, view formula) map[string]interface{} {
+	matching := []map[string]interface{}{}
+	for _, record := range s.tables[tableName] {
+		if (filter != nil && !truthy(filter(record))) || (view != nil && !truthy(view(record))) {
+			continue
+		}
+		matching = append(matching, render(record, query))
+	}
+	if maxRecords, err := strconv.Atoi(query.Get("maxRecords")); err == nil && maxRecords < len(matching) {
+		matching = matching[:maxRecords]
+	}
+
+	// Offsets are just the index of the first record on the page
+	start, _ := strconv.Atoi(query.Get("offset"))
+	if start > len(matching) {
+		start = len(matching)
+	}
+	end := start + s.PageSize
+	response := map[string]interface{}{}
+	if end < len(matching) {
+		response["offset"] = strconv.Itoa(end)
+	} else {
+		end = len(matching)
+	}
+	response["records"] = matching[start:end]
+	return response
+}
+
+// render a record for a list response, applying fields[] and recordMetadata[]
+func render(record map[string]interface{}, query url.Values) map[string]interface{} {
+	fields := record["fields"].(map[string]interface{})
+	if only, ok := query["fields[]"]; ok {
+		fields = map[string]interface{}{}
+		for _, name := range only {
+			if value, ok := record["fields"].(map[string]interface{})[name]; ok {
+				fields[name] = value
+			}
+		}
+	}
+	rendered := map[string]interface{}{"id": record["id"], "createdTime": record["createdTime"], "fields": fields}
+	for _, metadata := range query["recordMetadata[]"] {
+		if metadata == "commentCount" {
+			commentCount, _ := record["commentCount"].(int)
+			rendered["commentCount"] = commentCount
+		}
+	}
+	return rendered
+}
+
+// add a record, must hold the lock
+func (s *Server) add(tableName string, fields map[string]interface{}) map[string]interface{} {
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	s.nextID++
+	record := map[string]interface{}{
+		"id":          fmt.Sprintf("rec%014d", s.nextID),
+		"createdTime": time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		"fields":      fields,
+	}
+	s.tables[tableName] = append(s.tables[tableName], record)
+	return record
+}
+
+// remove a record, must hold the lock. Returns false if it did not exist
+func (s *Server) remove(tableName, recordID string) bool {
+	records := s.tables[tableName]
+	for i, record := range records {
+		if record["id"] == recordID {
+			s.tables[tableName] = append(records[:i:i], records[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// find a record, must hold the lock
+func (s *Server) find(tableName, recordID string) map[string]interface{} {
+	for _, record := range s.tables[tableName] {
+		if record["id"] == recordID {
+			return record
+		}
+	}
+	return nil
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	s.requests++
+
+	body := map[string]interface{}{}
+	if raw, _ := ioutil.ReadAll(r.Body); len(raw) > 0 {
+		json.Unmarshal(raw, &body)
+	}
+	s.lastBody = body
+
+	if len(s.failures) > 0 && s.failures[0].after > 0 {
+		s.failures[0].after--
+	} else if len(s.failures) > 0 {
+		failure := s.failures[0]
+		s.failures = s.failures[1:]
+		writeError(w, failure.statusCode, failure.errorType)
+		return
+	}
+
+	// Metadata API is /v0/meta/bases and /v0/meta/bases/<base>/tables
+	if r.URL.Path == "/v0/meta/bases" {
+		bases := s.bases
+		if bases == nil {
+			bases = []interface{}{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"bases": bases})
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/v0/meta/") {
+		schema := s.schema
+		if schema == nil {
+			schema = []interface{}{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"tables": schema})
+		return
+	}
+
+	// Webhooks API is /v0/bases/<base>/webhooks[/<id>[/refresh]]
+	if strings.HasPrefix(r.URL.Path, "/v0/bases/") {
+		s.handleWebhooks(w, r, body)
+		return
+	}
+
+	// Path is /v0/<base>/<table>[/<record>]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) < 3 {
+		writeError(w, http.StatusNotFound, "NOT_FOUND")
+		return
+	}
+	tableName, _ := url.PathUnescape(parts[2])
+	recordID := ""
+	if len(parts) > 3 {
+		recordID = parts[3]
+	}
+
+	switch {
+	case r.Method == "GET" && recordID == "":
+		s.listRequests[tableName]++
+		var filter formula
+		if text := r.URL.Query().Get("filterByFormula"); text != "" {
+			var err error
+			if filter, err = parseFormula(text); err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "INVALID_FILTER_BY_FORMULA")
+				return
+			}
+		}
+		var view formula
+		if name := r.URL.Query().Get("view"); name != "" {
+			if view = s.views[tableName][name]; view == nil {
+				writeError(w, http.StatusUnprocessableEntity, "VIEW_NAME_NOT_FOUND")
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(s.list(tableName, r.URL.Query(), filter, view))
+	case r.Method == "GET":
+		record := s.find(tableName, recordID)
+		if record == nil {
+			writeError(w, http.StatusNotFound, "NOT_FOUND")
+			return
+		}
+		json.NewEncoder(w).Encode(record)
+	case r.Method == "POST" && body["records"] != nil:
+		// Batch create of {"records": [{"fields"}]}
+		records, _ := body["records"].([]interface{})
+		created := []interface{}{}
+		for _, item := range records {
+			create, _ := item.(map[string]interface{})
+			fields, _ := create["fields"].(map[string]interface{})
+			created = append(created, s.add(tableName, fields))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"records": created})
+	case r.Method == "POST":
+		fields, _ := body["fields"].(map[string]interface{})
+		json.NewEncoder(w).Encode(s.add(tableName, fields))
+	case r.Method == "PATCH" && recordID == "":
+		// Batch update of {"records": [{"id", "fields"}]}
+		records, _ := body["records"].([]interface{})
+		updated := []interface{}{}
+		for _, item := range records {
+			update, _ := item.(map[string]interface{})
+			id, _ := update["id"].(string)
+			if s.find(tableName, id) == nil {
+				writeError(w, http.StatusNotFound, "NOT_FOUND")
+				return
+			}
+		}
+		for _, item := range records {
+			update := item.(map[string]interface{})
+			record := s.find(tableName, update["id"].(string))
+			fields, _ := update["fields"].(map[string]interface{})
+			for key, value := range fields {
+				record["fields"].(map[string]interface{})[key] = value
+			}
+			updated = append(updated, record)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"records": updated})
+	case r.Method == "PATCH":
+		record := s.find(tableName, recordID)
+		if record == nil {
+			writeError(w, http.StatusNotFound, "NOT_FOUND")
+			return
+		}
+		fields, _ := body["fields"].(map[string]interface{})
+		for key, value := range fields {
+			record["fields"].(map[string]interface{})[key] = value
+		}
+		json.NewEncoder(w).Encode(record)
+	case r.Method == "DELETE" && recordID == "":
+		// Batch delete of ?records[]=id
+		ids := r.URL.Query()["records[]"]
+		for _, id := range ids {
+			if s.find(tableName, id) == nil {
+				writeError(w, http.StatusNotFound, "NOT_FOUND")
+				return
+			}
+		}
+		deleted := []interface{}{}
+		for _, id := range ids {
+			s.remove(tableName, id)
+			deleted = append(deleted, map[string]interface{}{"id": id, "deleted": true})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"records": deleted})
+	case r.Method == "DELETE":
+		if !s.remove(tableName, recordID) {
+			writeError(w, http.StatusNotFound, "NOT_FOUND")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": recordID, "deleted": true})
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND")
+	}
+}
+
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "POST" && len(parts) == 4:
+		s.nextID++
+		id := fmt.Sprintf("ach%014d", s.nextID)
+		s.webhooks[id], _ = body["notificationUrl"].(string)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":              id,
+			"macSecretBase64": base64.StdEncoding.EncodeToString(WebhookSecret),
+			"expirationTime":  time.Now().Add(time.Hour * 24 * 7).UTC().Format("2006-01-02T15:04:05.000Z"),
+		})
+	case r.Method == "POST" && len(parts) == 6:
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	case r.Method == "DELETE" && len(parts) == 5:
+		delete(s.webhooks, parts[4])
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND")
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, errorType string) {
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"type": errorType, "message": errorType}})
+}
+
+// redirectTransport sends every request to the fake server instead of airtable
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Change a copy, RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
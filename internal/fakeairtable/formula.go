@@ -0,0 +1,248 @@
+package fakeairtable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// formula is a parsed filterByFormula, evaluated against a record.
+// Only a small subset of airtable formulas is supported: {Field} references, string and number literals,
+// =, !=, <, >, <=, >=, and the functions AND, OR, NOT, RECORD_ID, BLANK, TRUE and FALSE
+type formula func(record map[string]interface{}) interface{}
+
+// parseFormula Parse a formula, returning an error for anything unsupported
+func parseFormula(text string) (formula, error) {
+	p := &formulaParser{text: text}
+	f, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.text) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.text[p.pos:], p.pos)
+	}
+	return f, nil
+}
+
+// truthy Check if a formula value counts as true
+func truthy(value interface{}) bool {
+	switch value := value.(type) {
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value != ""
+	case nil:
+		return false
+	default:
+		return true
+	}
+}
+
+type formulaParser struct {
+	text string
+	pos  int
+}
+
+func (p *formulaParser) skipSpace() {
+	for p.pos < len(p.text) && strings.ContainsRune(" \t\r\n", rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+// comparison is operand [op operand]
+func (p *formulaParser) comparison() (formula, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if !strings.HasPrefix(p.text[p.pos:], op) {
+			continue
+		}
+		p.pos += len(op)
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(record map[string]interface{}) interface{} {
+			return compare(op, left(record), right(record))
+		}, nil
+	}
+	return left, nil
+}
+
+// operand is a literal, field reference or function call
+func (p *formulaParser) operand() (formula, error) {
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return nil, fmt.Errorf("unexpected end of formula")
+	}
+	switch c := p.text[p.pos]; {
+	case c == '{':
+		end := strings.IndexByte(p.text[p.pos:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed field reference")
+		}
+		name := p.text[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		return func(record map[string]interface{}) interface{} {
+			return record["fields"].(map[string]interface{})[name]
+		}, nil
+	case c == '\'' || c == '"':
+		value, err := p.stringLiteral(c)
+		if err != nil {
+			return nil, err
+		}
+		return func(map[string]interface{}) interface{} { return value }, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.text) && strings.ContainsRune("0123456789.", rune(p.text[p.pos])) {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+		if err != nil {
+			return nil, err
+		}
+		return func(map[string]interface{}) interface{} { return value }, nil
+	default:
+		return p.call()
+	}
+}
+
+// stringLiteral is a quoted string with backslash escapes
+func (p *formulaParser) stringLiteral(quote byte) (string, error) {
+	p.pos++
+	value := strings.Builder{}
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		p.pos++
+		switch {
+		case c == '\\' && p.pos < len(p.text):
+			value.WriteByte(p.text[p.pos])
+			p.pos++
+		case c == quote:
+			return value.String(), nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unclosed string")
+}
+
+// call is NAME(args...)
+func (p *formulaParser) call() (formula, error) {
+	start := p.pos
+	for p.pos < len(p.text) && (p.text[p.pos] == '_' || (p.text[p.pos] >= 'A' && p.text[p.pos] <= 'Z')) {
+		p.pos++
+	}
+	name := p.text[start:p.pos]
+	p.skipSpace()
+	if name == "" || p.pos >= len(p.text) || p.text[p.pos] != '(' {
+		return nil, fmt.Errorf("unexpected %q at %d", p.text[start:], start)
+	}
+	p.pos++
+
+	args := []formula{}
+	for {
+		p.skipSpace()
+		if p.pos < len(p.text) && p.text[p.pos] == ')' {
+			p.pos++
+			break
+		}
+		if len(args) > 0 {
+			if p.text[p.pos] != ',' {
+				return nil, fmt.Errorf("expected , at %d", p.pos)
+			}
+			p.pos++
+		}
+		arg, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			return nil, fmt.Errorf("unclosed call to %s", name)
+		}
+	}
+
+	switch name {
+	case "AND", "OR":
+		return func(record map[string]interface{}) interface{} {
+			for _, arg := range args {
+				if truthy(arg(record)) != (name == "AND") {
+					return name == "OR"
+				}
+			}
+			return name == "AND"
+		}, nil
+	case "NOT":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("NOT takes one argument")
+		}
+		return func(record map[string]interface{}) interface{} { return !truthy(args[0](record)) }, nil
+	case "RECORD_ID":
+		return func(record map[string]interface{}) interface{} { return record["id"] }, nil
+	case "BLANK":
+		return func(map[string]interface{}) interface{} { return nil }, nil
+	case "TRUE", "FALSE":
+		return func(map[string]interface{}) interface{} { return name == "TRUE" }, nil
+	}
+	return nil, fmt.Errorf("unsupported function %s", name)
+}
+
+// compare two values, numerically if both are numbers and as text otherwise
+func compare(op string, left, right interface{}) bool {
+	leftNumber, leftOK := left.(float64)
+	rightNumber, rightOK := right.(float64)
+	order := 0
+	if leftOK && rightOK {
+		switch {
+		case leftNumber < rightNumber:
+			order = -1
+		case leftNumber > rightNumber:
+			order = 1
+		}
+	} else {
+		order = strings.Compare(text(left), text(right))
+	}
+
+	switch op {
+	case "=":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case ">":
+		return order > 0
+	case "<=":
+		return order <= 0
+	default:
+		return order >= 0
+	}
+}
+
+// text Get the text form of a value, blank for nil
+func text(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		if value {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(value)
+	}
+}
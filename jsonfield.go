@@ -0,0 +1,40 @@
+package airtablewatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MergeJSONField Update keys of a JSON object stored in a text field, leaving the other keys alone.
+// Keys in patch set to nil are removed. An empty field is treated as {}.
+// This is a non-atomic read-modify-write, concurrent writers to the same field can overwrite each other.
+// With SerializeRowWrites it is at least safe from other writes to the row from this watcher
+func (t *Watcher) MergeJSONField(tableName, recordID, fieldName string, patch map[string]interface{}) error {
+	defer t.lockRow(tableName, recordID)()
+
+	row, err := t.GetRow(tableName, recordID)
+	if err != nil {
+		return fmt.Errorf("error getting row: %w", err)
+	}
+
+	object := map[string]interface{}{}
+	if text := row.GetFieldString(fieldName); text != "" {
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return fmt.Errorf("field %s is not a JSON object: %w", fieldName, err)
+		}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(object, key)
+			continue
+		}
+		object[key] = value
+	}
+
+	JSON, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("error encoding field %s: %w", fieldName, err)
+	}
+	return t.setRow(context.Background(), tableName, recordID, map[string]interface{}{fieldName: string(JSON)})
+}
@@ -0,0 +1,29 @@
+package airtablewatcher
+
+import (
+	"testing"
+)
+
+func TestMergeJSONField(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"Meta": `{"a":1,"b":"two"}`, "Text": "not json"})
+	empty := fake.AddRecord("Tasks", map[string]interface{}{})
+	watcher := newFakeWatcher(t, fake)
+
+	if err := watcher.MergeJSONField("Tasks", id, "Meta", map[string]interface{}{"b": nil, "c": true}); err != nil {
+		t.Fatal(err)
+	}
+	if meta := fake.Record("Tasks", id)["Meta"]; meta != `{"a":1,"c":true}` {
+		t.Errorf("Incorrect merge %v", meta)
+	}
+	if err := watcher.MergeJSONField("Tasks", empty, "Meta", map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if meta := fake.Record("Tasks", empty)["Meta"]; meta != `{"a":1}` {
+		t.Errorf("Incorrect merge into empty field %v", meta)
+	}
+	if err := watcher.MergeJSONField("Tasks", id, "Text", map[string]interface{}{"a": 1}); err == nil {
+		t.Errorf("Expected error merging into non JSON text")
+	}
+}
@@ -0,0 +1,130 @@
+package airtablewatcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultLeaderLease is how long a ConfigLeaderLock is held without being renewed
+const DefaultLeaderLease = time.Minute
+
+// LeaderElector decides which of several watchers sharing a base is the active one.
+// With Watcher.Leader set, Start only polls while Acquire reports this watcher as leader
+type LeaderElector interface {
+	// Acquire Take or renew leadership for workerID, returning whether workerID now leads
+	Acquire(ctx context.Context, workerID string) (bool, error)
+	// Release Give up leadership if workerID holds it
+	Release(ctx context.Context, workerID string) error
+}
+
+// ConfigLeaderLock is a LeaderElector backed by a row in the watcher's config table.
+// The row's Value holds the leader's worker ID and when its lease runs out, renewed every poll.
+// Airtable has no compare-and-set, so a write is confirmed by reading it back. Two watchers writing in the
+// same instant both read back the last write, so only one of them leads, but keep PollInterval well under
+// Lease so a leader renews before a standby sees the lease as expired
+type ConfigLeaderLock struct {
+	Watcher *Watcher
+	// Config key of the lock row
+	Key string
+	// How long leadership lasts without a renewal
+	Lease time.Duration
+}
+
+// NewConfigLeaderLock Create a leader lock on a config key, with a DefaultLeaderLease
+func NewConfigLeaderLock(watcher *Watcher, key string) *ConfigLeaderLock {
+	return &ConfigLeaderLock{Watcher: watcher, Key: key, Lease: DefaultLeaderLease}
+}
+
+// Acquire implements LeaderElector
+func (l *ConfigLeaderLock) Acquire(ctx context.Context, workerID string) (bool, error) {
+	row, err := l.Watcher.configRow(ctx, l.Key)
+	if err != nil {
+		return false, err
+	}
+	if row != nil {
+		holder, expires := parseLease(row.GetFieldString("Value"))
+		if holder != workerID && time.Now().Before(expires) {
+			return false, nil
+		}
+	}
+
+	lease := fmt.Sprintf("%s %s", workerID, time.Now().Add(l.Lease).UTC().Format(time.RFC3339Nano))
+	if row == nil {
+		created, err := l.Watcher.createRecords(ctx, l.Watcher.ConfigTableName, []map[string]interface{}{{"Key": l.Key, "Value": lease}})
+		if err != nil {
+			return false, fmt.Errorf("error creating leader lock: %w", err)
+		}
+		row = &created[0]
+	} else if err := l.Watcher.setRow(ctx, l.Watcher.ConfigTableName, row.ID, map[string]interface{}{"Value": lease}); err != nil {
+		return false, fmt.Errorf("error renewing leader lock: %w", err)
+	}
+
+	// Someone else may have written in between
+	confirmed, err := l.Watcher.GetRowContext(ctx, l.Watcher.ConfigTableName, row.ID)
+	if err != nil {
+		return false, err
+	}
+	holder, _ := parseLease(confirmed.GetFieldString("Value"))
+	return holder == workerID, nil
+}
+
+// Release implements LeaderElector
+func (l *ConfigLeaderLock) Release(ctx context.Context, workerID string) error {
+	row, err := l.Watcher.configRow(ctx, l.Key)
+	if err != nil || row == nil {
+		return err
+	}
+	if holder, _ := parseLease(row.GetFieldString("Value")); holder != workerID {
+		return nil
+	}
+	return l.Watcher.setRow(ctx, l.Watcher.ConfigTableName, row.ID, map[string]interface{}{"Value": ""})
+}
+
+// parseLease Split a lock value into its holder and expiry, blank for an unheld lock
+func parseLease(value string) (string, time.Time) {
+	parts := strings.SplitN(value, " ", 2)
+	if len(parts) != 2 {
+		return "", time.Time{}
+	}
+	expires, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return "", time.Time{}
+	}
+	return parts[0], expires
+}
+
+// lead Check with Leader whether this watcher should poll, logging when leadership changes hands.
+// Errors count as not leading, so a watcher that can't reach airtable steps down
+func (t *Watcher) lead(ctx context.Context) bool {
+	leading, err := t.Leader.Acquire(ctx, t.WorkerID)
+	if err != nil {
+		t.logEvent(Event{Type: EventError, Detail: fmt.Sprintf("error acquiring leadership: %s", err)})
+	}
+
+	t.Lock()
+	changed := leading != t.leading
+	t.leading = leading
+	t.Unlock()
+	if changed && leading {
+		t.logEvent(Event{Type: EventLeaderAcquired, Detail: t.WorkerID})
+	} else if changed {
+		t.logEvent(Event{Type: EventLeaderLost, Detail: t.WorkerID})
+	}
+	return leading
+}
+
+// stepDown Release leadership if this watcher holds it
+func (t *Watcher) stepDown() {
+	t.Lock()
+	leading := t.leading
+	t.leading = false
+	t.Unlock()
+	if t.Leader == nil || !leading {
+		return
+	}
+	if err := t.Leader.Release(context.Background(), t.WorkerID); err != nil {
+		t.logEvent(Event{Type: EventError, Detail: fmt.Sprintf("error releasing leadership: %s", err)})
+	}
+}
@@ -0,0 +1,79 @@
+package airtablewatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConfigLeaderLock(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	primary := newFakeWatcher(t, fake)
+	primary.WorkerID = "primary"
+	standby := newFakeWatcher(t, fake)
+	standby.WorkerID = "standby"
+	ctx := context.Background()
+
+	primaryLock := NewConfigLeaderLock(primary, "leader")
+	standbyLock := NewConfigLeaderLock(standby, "leader")
+	if leading, err := primaryLock.Acquire(ctx, primary.WorkerID); err != nil || !leading {
+		t.Fatalf("Expected primary to lead: %v %v", leading, err)
+	}
+	if leading, _ := standbyLock.Acquire(ctx, standby.WorkerID); leading {
+		t.Errorf("Expected standby not to lead while the lease is held")
+	}
+	// Renewing keeps leadership
+	if leading, _ := primaryLock.Acquire(ctx, primary.WorkerID); !leading {
+		t.Errorf("Expected primary to renew")
+	}
+	if len(fake.RecordIDs("Config")) != 1 {
+		t.Errorf("Expected a single lock row, got %d", len(fake.RecordIDs("Config")))
+	}
+
+	// Releasing hands over
+	if err := standbyLock.Release(ctx, standby.WorkerID); err != nil {
+		t.Fatal(err)
+	}
+	if err := primaryLock.Release(ctx, primary.WorkerID); err != nil {
+		t.Fatal(err)
+	}
+	if leading, _ := standbyLock.Acquire(ctx, standby.WorkerID); !leading {
+		t.Errorf("Expected standby to lead once released")
+	}
+
+	// So does an expired lease
+	standbyLock.Lease = -time.Second
+	standbyLock.Acquire(ctx, standby.WorkerID)
+	if leading, _ := primaryLock.Acquire(ctx, primary.WorkerID); !leading {
+		t.Errorf("Expected primary to take over an expired lease")
+	}
+}
+
+func TestStartLeader(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	primary := newFakeWatcher(t, fake)
+	primary.WorkerID = "primary"
+	if leading, _ := NewConfigLeaderLock(primary, "leader").Acquire(context.Background(), primary.WorkerID); !leading {
+		t.Fatal("Expected primary to lead")
+	}
+
+	standby := newFakeWatcher(t, fake)
+	standby.WorkerID = "standby"
+	standby.PollInterval = time.Millisecond * 10
+	standby.Leader = NewConfigLeaderLock(standby, "leader")
+	ran := make(chan string, 10)
+	standby.RegisterFunction("Tasks", "State", []string{"ToDo"}, recordRuns(ran))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	standby.Start(ctx)
+	if len(ran) != 0 {
+		t.Errorf("Standby dispatched while not leading")
+	}
+	if fake.ListRequests("Tasks") != 0 {
+		t.Errorf("Standby polled while not leading")
+	}
+}
@@ -0,0 +1,204 @@
+package airtablewatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListOptions narrows down and adds to the rows returned by GetRowsWithOptions
+type ListOptions struct {
+	// Only return rows where this formula is true, e.g. "{State} = 'ToDo'"
+	FilterByFormula string
+	// Only return rows in this view, in the view's order
+	View string
+	// Only return these fields
+	Fields []string
+	// Return at most this many rows, 0 for all
+	MaxRecords int
+	// Include the number of comments on each row, see Row.CommentCount
+	CommentCount bool
+}
+
+// query Encode the options as list request parameters
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	if o.FilterByFormula != "" {
+		query.Set("filterByFormula", o.FilterByFormula)
+	}
+	if o.View != "" {
+		query.Set("view", o.View)
+	}
+	for _, field := range o.Fields {
+		query.Add("fields[]", field)
+	}
+	if o.MaxRecords > 0 {
+		query.Set("maxRecords", strconv.Itoa(o.MaxRecords))
+	}
+	if o.CommentCount {
+		query.Add("recordMetadata[]", "commentCount")
+	}
+	return query
+}
+
+// GetRowsWithOptions Get the rows of a table narrowed down by options
+func (t *Watcher) GetRowsWithOptions(tableName string, options ListOptions) ([]Row, error) {
+	query := options.query()
+	cacheKey := query.Encode()
+	if rows, ok := t.cachedRows(tableName, cacheKey); ok {
+		return rows, nil
+	}
+
+	rows, err := t.listRecords(context.Background(), tableName, query)
+	if err != nil {
+		return nil, err
+	}
+	t.cacheRows(tableName, cacheKey, rows)
+
+	return rows, nil
+}
+
+// listPage is a single page of a list records response
+type listPage struct {
+	Records []Row  `json:"records"`
+	Offset  string `json:"offset"`
+}
+
+// withNumberFormat Have rows fetched by the watcher use its NumberFormat
+func (t *Watcher) withNumberFormat(rows []Row) {
+	for i := range rows {
+		rows[i].numberFormat = &t.NumberFormat
+	}
+}
+
+// listRecords Get every row matching the query, following pagination
+func (t *Watcher) listRecords(ctx context.Context, tableName string, query url.Values) ([]Row, error) {
+	rows := []Row{}
+	offset := ""
+	for {
+		page, err := t.listPage(ctx, tableName, query, offset)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, page.Records...)
+		if page.Offset == "" {
+			return rows, nil
+		}
+		offset = page.Offset
+	}
+}
+
+// listPage Get a single page of rows starting at offset ("" for the first page)
+func (t *Watcher) listPage(ctx context.Context, tableName string, query url.Values, offset string) (*listPage, error) {
+	pageQuery := url.Values{}
+	for key, values := range query {
+		pageQuery[key] = values
+	}
+	if offset != "" {
+		pageQuery.Set("offset", offset)
+	}
+
+	rawBody, err := t.request(ctx, "GET", t.tablePath(tableName), pageQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+	page := &listPage{}
+	if err := json.Unmarshal(rawBody, page); err != nil {
+		return nil, fmt.Errorf("error decoding rows: %w", err)
+	}
+	t.withNumberFormat(page.Records)
+	return page, nil
+}
+
+// DistinctValues Get the unique non-empty values of a field across a table, sorted.
+// Each value of a multiple select or other list field counts separately
+func (t *Watcher) DistinctValues(tableName, fieldName string) ([]string, error) {
+	rows, err := t.GetRowsWithOptions(tableName, ListOptions{Fields: []string{fieldName}})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, row := range rows {
+		values, ok := row.GetField(fieldName).([]interface{})
+		if !ok {
+			values = []interface{}{row.GetField(fieldName)}
+		}
+		for _, value := range values {
+			if s := fieldString(value, row.numberFormat); s != "" {
+				seen[s] = true
+			}
+		}
+	}
+
+	distinct := make([]string, 0, len(seen))
+	for value := range seen {
+		distinct = append(distinct, value)
+	}
+	sort.Strings(distinct)
+
+	return distinct, nil
+}
+
+// GetRowIDs Get the record IDs of every row in a table, without their field data.
+// Much cheaper than GetRows when comparing which rows exist
+func (t *Watcher) GetRowIDs(tableName string) ([]string, error) {
+	// A single blank field name asks for no fields at all
+	rows, err := t.GetRowsWithOptions(tableName, ListOptions{Fields: []string{""}})
+	if err != nil {
+		return nil, err
+	}
+	return recordIDs(rows), nil
+}
+
+// maxFormulaLength keeps filterByFormula well under airtable's 16k URL limit
+const maxFormulaLength = 8000
+
+// GetRowsByIDs Get rows by record ID in as few requests as possible, in the order of ids.
+// IDs that don't exist are left out
+func (t *Watcher) GetRowsByIDs(tableName string, ids []string) ([]Row, error) {
+	ctx := context.Background()
+	found := map[string]Row{}
+	for _, formula := range recordIDFormulas(ids, maxFormulaLength) {
+		rows, err := t.listRecords(ctx, tableName, ListOptions{FilterByFormula: formula}.query())
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			found[row.ID] = row
+		}
+	}
+
+	rows := make([]Row, 0, len(found))
+	for _, id := range ids {
+		if row, ok := found[id]; ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// recordIDFormulas Build OR(RECORD_ID()='...', ...) formulas matching ids, each at most maxLength long
+func recordIDFormulas(ids []string, maxLength int) []string {
+	formulas := []string{}
+	clauses := []string{}
+	length := len("OR()")
+	for _, id := range ids {
+		clause := "RECORD_ID()=" + formulaString(id)
+		if len(clauses) > 0 && length+len(clause)+1 > maxLength {
+			formulas = append(formulas, "OR("+strings.Join(clauses, ",")+")")
+			clauses = clauses[:0]
+			length = len("OR()")
+		}
+		clauses = append(clauses, clause)
+		length += len(clause) + 1
+	}
+	if len(clauses) > 0 {
+		formulas = append(formulas, "OR("+strings.Join(clauses, ",")+")")
+	}
+	return formulas
+}
@@ -0,0 +1,84 @@
+package airtablewatcher
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDistinctValues(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"Category": "Bug", "Tags": []interface{}{"a", "b"}})
+	fake.AddRecord("Tasks", map[string]interface{}{"Category": "Feature", "Tags": []interface{}{"b"}})
+	fake.AddRecord("Tasks", map[string]interface{}{"Category": "Bug"})
+	fake.AddRecord("Tasks", map[string]interface{}{})
+	watcher := newFakeWatcher(t, fake)
+
+	values, err := watcher.DistinctValues("Tasks", "Category")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []string{"Bug", "Feature"}) {
+		t.Errorf("Incorrect distinct values %v", values)
+	}
+	values, _ = watcher.DistinctValues("Tasks", "Tags")
+	if !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("Incorrect distinct list values %v", values)
+	}
+}
+
+func TestGetRowIDs(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"Name": "a", "Notes": "long"})
+	fake.AddRecord("Tasks", map[string]interface{}{"Name": "b"})
+	watcher := newFakeWatcher(t, fake)
+
+	ids, err := watcher.GetRowIDs("Tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, fake.RecordIDs("Tasks")) {
+		t.Errorf("Incorrect ids %v", ids)
+	}
+	rows, _ := watcher.GetRowsWithOptions("Tasks", ListOptions{Fields: []string{""}})
+	for _, row := range rows {
+		if row.GetField("Name") != nil {
+			t.Errorf("Expected no field data, got %v", row.Fields)
+		}
+	}
+}
+
+func TestGetRowsByIDs(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	ids := []string{}
+	for i := 0; i < 5; i++ {
+		ids = append(ids, fake.AddRecord("Tasks", map[string]interface{}{"Name": strconv.Itoa(i)}))
+	}
+	watcher := newFakeWatcher(t, fake)
+
+	wanted := []string{ids[3], "recMISSINGMISSING", ids[1]}
+	rows, err := watcher.GetRowsByIDs("Tasks", wanted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].ID != ids[3] || rows[1].ID != ids[1] {
+		t.Errorf("Incorrect rows %v", rows)
+	}
+	if fake.ListRequests("Tasks") != 1 {
+		t.Errorf("Expected a single request, got %d", fake.ListRequests("Tasks"))
+	}
+
+	// Long lists are split over several formulas
+	formulas := recordIDFormulas(ids, 100)
+	if len(formulas) < 2 {
+		t.Errorf("Expected ids to be split, got %v", formulas)
+	}
+	for _, formula := range formulas {
+		if len(formula) > 100 {
+			t.Errorf("Formula too long %s", formula)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package airtablewatcher
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// MetricsPrefix starts the name of every metric MetricsHandler serves
+const MetricsPrefix = "airtablewatcher_"
+
+// countEvent Add an event to the metrics counters, must hold the lock
+func (t *Watcher) countEvent(event Event) {
+	if t.eventCounts == nil {
+		t.eventCounts = map[string]int{}
+	}
+	t.eventCounts[event.Type]++
+	if event.Type == EventFinished || event.Type == EventCanceled {
+		t.actionDuration += event.Duration
+	}
+}
+
+// MetricsHandler Get a handler serving the watcher's counters in the Prometheus text format, to mount at /metrics.
+// Events are counted by type from when the watcher was created, whether or not EventLogSize keeps them
+func (t *Watcher) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health := t.Health()
+		t.Lock()
+		counts := make(map[string]int, len(t.eventCounts))
+		for eventType, count := range t.eventCounts {
+			counts[eventType] = count
+		}
+		actions := counts[EventFinished] + counts[EventCanceled]
+		actionSeconds := t.actionDuration.Seconds()
+		t.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetric(w, "events_total", "counter", "Events logged by type", nil)
+		types := make([]string, 0, len(counts))
+		for eventType := range counts {
+			types = append(types, eventType)
+		}
+		sort.Strings(types)
+		for _, eventType := range types {
+			fmt.Fprintf(w, "%sevents_total{type=%q} %d\n", MetricsPrefix, eventType, counts[eventType])
+		}
+
+		writeMetric(w, "polls_total", "counter", "Completed poll cycles", health.Polls)
+		writeMetric(w, "in_flight", "gauge", "Action functions running", health.InFlight)
+		writeMetric(w, "last_poll_duration_seconds", "gauge", "How long the last poll cycle took", health.LastPollDuration.Seconds())
+		writeMetric(w, "action_duration_seconds", "summary", "Run time of finished and canceled actions", nil)
+		fmt.Fprintf(w, "%saction_duration_seconds_sum %g\n", MetricsPrefix, actionSeconds)
+		fmt.Fprintf(w, "%saction_duration_seconds_count %d\n", MetricsPrefix, actions)
+		writeMetric(w, "circuit_state", "gauge", "Circuit breaker state, 1 for the current one", nil)
+		for _, state := range []string{CircuitClosed, CircuitHalfOpen, CircuitOpen} {
+			current := 0
+			if state == health.CircuitState {
+				current = 1
+			}
+			fmt.Fprintf(w, "%scircuit_state{state=%q} %d\n", MetricsPrefix, state, current)
+		}
+	})
+}
+
+// writeMetric Write a metric's HELP and TYPE lines, followed by its value unless value is nil
+func writeMetric(w http.ResponseWriter, name, metricType, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s %s\n", MetricsPrefix, name, help, MetricsPrefix, name, metricType)
+	if value != nil {
+		fmt.Fprintf(w, "%s%s %v\n", MetricsPrefix, name, value)
+	}
+}
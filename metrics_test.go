@@ -0,0 +1,36 @@
+package airtablewatcher
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {})
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	watcher.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"# TYPE airtablewatcher_events_total counter\n",
+		`airtablewatcher_events_total{type="finished"} 1` + "\n",
+		`airtablewatcher_events_total{type="matched"} 1` + "\n",
+		"airtablewatcher_polls_total 1\n",
+		"airtablewatcher_in_flight 0\n",
+		"airtablewatcher_action_duration_seconds_count 1\n",
+		`airtablewatcher_circuit_state{state="closed"} 1` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Missing %q in metrics:\n%s", expected, body)
+		}
+	}
+}
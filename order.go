@@ -0,0 +1,47 @@
+package airtablewatcher
+
+import "sort"
+
+// RowOrder reports whether row a should be processed before row b
+type RowOrder func(a, b *Row) bool
+
+// OrderByRecordID Process rows in record ID order
+func OrderByRecordID(a, b *Row) bool {
+	return a.ID < b.ID
+}
+
+// OrderByCreatedTime Process rows oldest first, ties broken by record ID
+func OrderByCreatedTime(a, b *Row) bool {
+	if !a.CreatedTime.Equal(b.CreatedTime) {
+		return a.CreatedTime.Before(b.CreatedTime)
+	}
+	return a.ID < b.ID
+}
+
+// OrderByNumber Get an order processing rows by a number field, lowest first, ties broken by record ID.
+// Use it with an autoNumber field for first in first out processing. Rows without a number in the field go last
+func OrderByNumber(fieldName string) RowOrder {
+	return func(a, b *Row) bool {
+		aNumber, aErr := a.GetFieldFloat(fieldName)
+		bNumber, bErr := b.GetFieldFloat(fieldName)
+		switch {
+		case aErr != nil || bErr != nil:
+			if (aErr == nil) != (bErr == nil) {
+				return aErr == nil
+			}
+		case aNumber != bNumber:
+			return aNumber < bNumber
+		}
+		return a.ID < b.ID
+	}
+}
+
+// sortRows sorts rows in place by order, doing nothing if order is nil
+func sortRows(rows []Row, order RowOrder) {
+	if order == nil {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return order(&rows[i], &rows[j])
+	})
+}
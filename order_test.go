@@ -0,0 +1,42 @@
+package airtablewatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSortRows(t *testing.T) {
+	now := time.Now()
+	rows := []Row{
+		{ID: "recC", CreatedTime: now},
+		{ID: "recA", CreatedTime: now.Add(time.Hour)},
+		{ID: "recB", CreatedTime: now},
+	}
+
+	sortRows(rows, OrderByRecordID)
+	if rows[0].ID != "recA" || rows[1].ID != "recB" || rows[2].ID != "recC" {
+		t.Errorf("Incorrect record ID order: %v", rows)
+	}
+
+	sortRows(rows, OrderByCreatedTime)
+	if rows[0].ID != "recB" || rows[1].ID != "recC" || rows[2].ID != "recA" {
+		t.Errorf("Incorrect created time order: %v", rows)
+	}
+}
+
+func TestOrderByNumber(t *testing.T) {
+	rows := []Row{
+		{ID: "recA", Fields: map[string]interface{}{"Number": float64(10)}},
+		{ID: "recB", Fields: map[string]interface{}{}},
+		{ID: "recC", Fields: map[string]interface{}{"Number": float64(2)}},
+		{ID: "recD", Fields: map[string]interface{}{"Number": float64(2)}},
+	}
+
+	sortRows(rows, OrderByNumber("Number"))
+	if rows[0].ID != "recC" || rows[1].ID != "recD" || rows[2].ID != "recA" || rows[3].ID != "recB" {
+		t.Errorf("Incorrect number order: %v", rows)
+	}
+	if number, err := rows[2].GetFieldInt("Number"); err != nil || number != 10 || rows[2].GetFieldString("Number") != "10" {
+		t.Errorf("Incorrect autoNumber value %d %s", number, err)
+	}
+}
@@ -0,0 +1,69 @@
+package airtablewatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Airtable permission levels, from least to most access
+const (
+	PermissionNone    = "none"
+	PermissionRead    = "read"
+	PermissionComment = "comment"
+	PermissionEdit    = "edit"
+	PermissionCreate  = "create"
+)
+
+// PermissionLevel Get the token's permission level on this base (one of the Permission constants).
+// The value is cached after the first successful call
+func (t *Watcher) PermissionLevel() (string, error) {
+	t.Lock()
+	level := t.permissionLevel
+	t.Unlock()
+	if level != "" {
+		return level, nil
+	}
+
+	query := url.Values{}
+	for {
+		rawBody, err := t.request(context.Background(), "GET", "meta/bases", query, nil)
+		if err != nil {
+			return "", fmt.Errorf("error getting permission level: %w", err)
+		}
+		response := struct {
+			Bases []struct {
+				ID              string `json:"id"`
+				PermissionLevel string `json:"permissionLevel"`
+			} `json:"bases"`
+			Offset string `json:"offset"`
+		}{}
+		if err := json.Unmarshal(rawBody, &response); err != nil {
+			return "", fmt.Errorf("error decoding bases: %w", err)
+		}
+
+		for _, base := range response.Bases {
+			if base.ID == t.airtableBase {
+				t.Lock()
+				t.permissionLevel = base.PermissionLevel
+				t.Unlock()
+				return base.PermissionLevel, nil
+			}
+		}
+		if response.Offset == "" {
+			return "", fmt.Errorf("base %s not found in token's bases", t.airtableBase)
+		}
+		query.Set("offset", response.Offset)
+	}
+}
+
+// CanWrite Check if the token can edit the row before attempting a write.
+// Airtable only reports permissions per base, so every row in the base gets the same answer
+func (t *Watcher) CanWrite(row *Row) (bool, error) {
+	level, err := t.PermissionLevel()
+	if err != nil {
+		return false, err
+	}
+	return level == PermissionEdit || level == PermissionCreate, nil
+}
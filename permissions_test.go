@@ -0,0 +1,26 @@
+package airtablewatcher
+
+import "testing"
+
+func TestCanWrite(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddBase("appOTHEROTHEROTHE", PermissionCreate)
+	fake.AddBase(testAirtableBase, PermissionRead)
+	watcher := newFakeWatcher(t, fake)
+
+	level, err := watcher.PermissionLevel()
+	if err != nil || level != PermissionRead {
+		t.Errorf("Incorrect permission level %q: %v", level, err)
+	}
+	if canWrite, err := watcher.CanWrite(&Row{}); err != nil || canWrite {
+		t.Errorf("Read only base should not be writable")
+	}
+
+	// Missing base
+	fake2 := newFakeAirtable()
+	defer fake2.Close()
+	if _, err := newFakeWatcher(t, fake2).CanWrite(&Row{}); err == nil {
+		t.Errorf("Expected error when base is not listed")
+	}
+}
@@ -0,0 +1,36 @@
+package airtablewatcher
+
+// TableResult counts what a poll cycle did with one table's rows
+type TableResult struct {
+	// Rows checked against the table's watchers
+	Scanned int
+	// Rows that triggered a watcher
+	Matched int
+	// Matched rows an action was started on
+	Fired int
+	// Matched rows left alone because they were already running or cooling down
+	Deduped int
+	// Matched rows held back, e.g. not settled yet or outside active hours
+	Skipped int
+	// Failed attempts to read the table's rows
+	Errored int
+}
+
+// PollResult is what a poll cycle did, by table name, see RunOnceResult
+type PollResult struct {
+	Tables map[string]TableResult
+}
+
+// Total Add up the results of every table
+func (p PollResult) Total() TableResult {
+	total := TableResult{}
+	for _, table := range p.Tables {
+		total.Scanned += table.Scanned
+		total.Matched += table.Matched
+		total.Fired += table.Fired
+		total.Deduped += table.Deduped
+		total.Skipped += table.Skipped
+		total.Errored += table.Errored
+	}
+	return total
+}
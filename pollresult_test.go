@@ -0,0 +1,47 @@
+package airtablewatcher
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunOnceResult(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	fake.AddRecord("Jobs", map[string]interface{}{"State": "Done"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.DispatchCooldown = time.Hour
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {})
+	watcher.RegisterFunction("Jobs", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {})
+
+	result, err := watcher.RunOnceResult(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks := result.Tables["Tasks"]; tasks != (TableResult{Scanned: 3, Matched: 2, Fired: 2}) {
+		t.Errorf("Incorrect first result %+v", tasks)
+	}
+
+	// Both rows are cooling down now, and one more is new
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	result, _ = watcher.RunOnceResult(context.Background())
+	if tasks := result.Tables["Tasks"]; tasks != (TableResult{Scanned: 4, Matched: 3, Fired: 1, Deduped: 2}) {
+		t.Errorf("Incorrect second result %+v", tasks)
+	}
+	if total := result.Total(); total.Scanned != 5 || total.Fired != 1 {
+		t.Errorf("Incorrect total %+v", total)
+	}
+
+	// Failed reads are counted against their table
+	fake.FailNext(http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE")
+	fake.FailNext(http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE")
+	result, err = watcher.RunOnceResult(context.Background())
+	if err == nil || result.Total().Errored == 0 {
+		t.Errorf("Expected errored tables, got %+v %v", result, err)
+	}
+}
@@ -0,0 +1,43 @@
+package airtablewatcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// defaultWorkerID Get an identity for this process, hostname and pid
+func defaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// markProcessing Write the worker ID to ProcessingField on the row, returning a function that clears it again.
+// The mark is always sent straight away so it shows while the action runs, only the clear is queued with CoalesceWrites.
+// Does nothing if ProcessingField is not set
+func (t *Watcher) markProcessing(ctx context.Context, tableName, recordID string) func() {
+	if t.ProcessingField == "" {
+		return func() {}
+	}
+	// Bypass SkipEmptyWrites, clearing the field is the point
+	set := func(value interface{}, action string, coalesce bool) {
+		fields := map[string]interface{}{t.ProcessingField: value}
+		if coalesce {
+			t.Lock()
+			t.queueWrite(tableName, recordID, fields, true)
+			t.Unlock()
+			return
+		}
+		defer t.lockRow(tableName, recordID)()
+		if err := t.setRow(ctx, tableName, recordID, fields); err != nil {
+			t.logEvent(Event{Type: EventError, TableName: tableName, RecordID: recordID, Detail: fmt.Sprintf("error %s %s: %s", action, t.ProcessingField, err)})
+		}
+	}
+	set(t.WorkerID, "setting", false)
+	return func() {
+		set(nil, "clearing", t.CoalesceWrites)
+	}
+}
@@ -0,0 +1,60 @@
+package airtablewatcher
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProcessingField(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.ProcessingField = "ProcessingBy"
+	watcher.WorkerID = "worker-1"
+
+	seen := ""
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		seen, _ = fake.Record(tableName, row.ID)["ProcessingBy"].(string)
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+	})
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	if seen != "worker-1" {
+		t.Errorf("Worker ID not written while processing, got %q", seen)
+	}
+	if value := fake.Record("Tasks", id)["ProcessingBy"]; value != nil {
+		t.Errorf("Worker ID not cleared, got %v", value)
+	}
+}
+
+func TestProcessingFieldErrors(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.ProcessingField = "ProcessingBy"
+
+	// The field can't be cleared once the action is done
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		fake.FailNext(http.StatusUnprocessableEntity, "INVALID_VALUE_FOR_COLUMN")
+	})
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	reported := false
+	for _, event := range watcher.RecentEvents(0) {
+		if event.Type == EventError && strings.Contains(event.Detail, "error clearing ProcessingBy") {
+			reported = true
+		}
+	}
+	if !reported {
+		t.Errorf("Expected the failed clear to be reported, got %v", watcher.RecentEvents(0))
+	}
+}
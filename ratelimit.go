@@ -0,0 +1,129 @@
+package airtablewatcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// requestLimiter spaces out requests so no more than a given number are sent per second
+type requestLimiter struct {
+	// the earliest time the next request may be sent
+	next time.Time
+	sync.Mutex
+}
+
+// wait Block until a request may be sent at requestsPerSecond, or the context is canceled
+func (r *requestLimiter) wait(ctx context.Context, requestsPerSecond float64) error {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / requestsPerSecond)
+
+	// Reserve a slot
+	r.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	sendAt := r.next
+	r.next = r.next.Add(interval)
+	r.Unlock()
+
+	delay := time.Until(sendAt)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// requestBudget counts requests sent in the last minute for Watcher.RequestsPerMinute
+type requestBudget struct {
+	// when each request in the window was sent, oldest first
+	sent []time.Time
+	// whether OnBudgetExhausted has been called for the current exhaustion
+	exhausted bool
+	sync.Mutex
+}
+
+// prune Drop requests that have left the window
+func (b *requestBudget) prune(now time.Time) {
+	i := 0
+	for i < len(b.sent) && now.Sub(b.sent[i]) >= time.Minute {
+		i++
+	}
+	b.sent = b.sent[i:]
+}
+
+// check Get how long until a request can be sent at now, counting it if count is set and it can be sent.
+// exhausted is true the first time a request has to wait since the budget last had room
+func (b *requestBudget) check(now time.Time, perMinute int, count bool) (wait time.Duration, exhausted bool) {
+	b.Lock()
+	defer b.Unlock()
+	b.prune(now)
+	if len(b.sent) < perMinute {
+		if count {
+			b.sent = append(b.sent, now)
+		}
+		b.exhausted = false
+		return 0, false
+	}
+	wait = b.sent[0].Add(time.Minute).Sub(now)
+	exhausted = !b.exhausted
+	b.exhausted = true
+	return wait, exhausted
+}
+
+// waitForBudget Block until RequestsPerMinute allows another request, or the context is canceled
+func (t *Watcher) waitForBudget(ctx context.Context) error {
+	if t.RequestsPerMinute <= 0 {
+		return nil
+	}
+	for {
+		wait, exhausted := t.requestBudget.check(time.Now(), t.RequestsPerMinute, true)
+		if wait <= 0 {
+			return nil
+		}
+		if exhausted && t.OnBudgetExhausted != nil {
+			t.OnBudgetExhausted(wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// budgetExhausted Check if RequestsPerMinute is used up, calling OnBudgetExhausted if that is news
+func (t *Watcher) budgetExhausted() bool {
+	if t.RequestsPerMinute <= 0 {
+		return false
+	}
+	wait, exhausted := t.requestBudget.check(time.Now(), t.RequestsPerMinute, false)
+	if wait <= 0 {
+		return false
+	}
+	if exhausted && t.OnBudgetExhausted != nil {
+		t.OnBudgetExhausted(wait)
+	}
+	return true
+}
+
+// RequestsLastMinute Get how many requests were sent to airtable in the last minute.
+// Only counted while RequestsPerMinute is set
+func (t *Watcher) RequestsLastMinute() int {
+	t.requestBudget.Lock()
+	defer t.requestBudget.Unlock()
+	t.requestBudget.prune(time.Now())
+	return len(t.requestBudget.sent)
+}
@@ -0,0 +1,83 @@
+package airtablewatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	limiter := requestLimiter{}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := limiter.wait(context.Background(), 50); err != nil {
+			t.Errorf(err.Error())
+			return
+		}
+	}
+	// 5 requests at 50/s should take at least 4 intervals of 20ms
+	if elapsed := time.Since(start); elapsed < time.Millisecond*80 {
+		t.Errorf("Requests were not spaced out, took %s", elapsed)
+	}
+
+	// No limit shouldn't wait
+	start = time.Now()
+	for i := 0; i < 100; i++ {
+		limiter.wait(context.Background(), 0)
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*10 {
+		t.Errorf("Unlimited requests waited %s", elapsed)
+	}
+}
+
+func TestRequestBudget(t *testing.T) {
+	budget := requestBudget{}
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if wait, _ := budget.check(now.Add(time.Duration(i)*time.Second), 2, true); wait != 0 {
+			t.Errorf("Request %d should fit in the budget", i)
+		}
+	}
+	if wait, exhausted := budget.check(now.Add(time.Second*30), 2, true); wait != time.Second*30 || !exhausted {
+		t.Errorf("Expected to wait for the oldest request to leave the window, got %s %v", wait, exhausted)
+	}
+	if _, exhausted := budget.check(now.Add(time.Second*31), 2, true); exhausted {
+		t.Errorf("Exhaustion should only be reported once")
+	}
+	if wait, _ := budget.check(now.Add(time.Minute), 2, true); wait != 0 {
+		t.Errorf("The budget should free up after a minute")
+	}
+}
+
+func TestRequestsPerMinute(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.RequestsPerMinute = 1
+	exhausted := 0
+	watcher.OnBudgetExhausted = func(wait time.Duration) {
+		exhausted++
+		if wait <= 0 || wait > time.Minute {
+			t.Errorf("Incorrect wait %s", wait)
+		}
+	}
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {})
+
+	if result, err := watcher.RunOnceResult(context.Background()); err != nil || result.Total().Scanned != 1 {
+		t.Errorf("First poll should run, got %+v %v", result, err)
+	}
+	if result, err := watcher.RunOnceResult(context.Background()); err != nil || result.Total().Scanned != 0 {
+		t.Errorf("Second poll should be skipped, got %+v %v", result, err)
+	}
+	if exhausted != 1 || watcher.RequestsLastMinute() != 1 {
+		t.Errorf("Expected one exhaustion after one request, got %d %d", exhausted, watcher.RequestsLastMinute())
+	}
+
+	// Other requests wait rather than fail
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, err := watcher.GetRowContext(ctx, "Tasks", id); err == nil {
+		t.Errorf("Expected the request to wait for the budget")
+	}
+}
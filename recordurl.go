@@ -0,0 +1,44 @@
+package airtablewatcher
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ParseRecordURL Get the base, table and record IDs from a record URL copied out of airtable,
+// e.g. https://airtable.com/appXXX/tblYYY/recZZZ. A view ID between the table and record is skipped
+func ParseRecordURL(recordURL string) (baseID, tableID, recordID string, err error) {
+	parsed, err := url.Parse(recordURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error parsing record url: %w", err)
+	}
+	for _, part := range strings.Split(parsed.Path, "/") {
+		switch {
+		case strings.HasPrefix(part, "app") && baseID == "":
+			baseID = part
+		case strings.HasPrefix(part, "tbl") && tableID == "":
+			tableID = part
+		case strings.HasPrefix(part, "rec") && recordID == "":
+			recordID = part
+		}
+	}
+	if baseID == "" || tableID == "" || recordID == "" {
+		return "", "", "", fmt.Errorf("not a record url: %s", recordURL)
+	}
+	return baseID, tableID, recordID, nil
+}
+
+// GetRowFromURL Get the row a record URL points to, see ParseRecordURL.
+// The URL must be for the watcher's own base
+func (t *Watcher) GetRowFromURL(recordURL string) (*Row, error) {
+	baseID, tableID, recordID, err := ParseRecordURL(recordURL)
+	if err != nil {
+		return nil, err
+	}
+	if baseID != t.airtableBase {
+		return nil, fmt.Errorf("record url is for base %s, not %s", baseID, t.airtableBase)
+	}
+	return t.GetRowContext(context.Background(), tableID, recordID)
+}
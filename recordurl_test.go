@@ -0,0 +1,36 @@
+package airtablewatcher
+
+import "testing"
+
+func TestParseRecordURL(t *testing.T) {
+	for recordURL, expected := range map[string][3]string{
+		"https://airtable.com/appAAAAAAAAAAAAAA/tblBBBBBBBBBBBBBB/recCCCCCCCCCCCCCC":                               {"appAAAAAAAAAAAAAA", "tblBBBBBBBBBBBBBB", "recCCCCCCCCCCCCCC"},
+		"https://airtable.com/appAAAAAAAAAAAAAA/tblBBBBBBBBBBBBBB/viwDDDDDDDDDDDDDD/recCCCCCCCCCCCCCC?blocks=hide": {"appAAAAAAAAAAAAAA", "tblBBBBBBBBBBBBBB", "recCCCCCCCCCCCCCC"},
+	} {
+		baseID, tableID, recordID, err := ParseRecordURL(recordURL)
+		if err != nil || [3]string{baseID, tableID, recordID} != expected {
+			t.Errorf("Incorrect parse of %s: %s %s %s %v", recordURL, baseID, tableID, recordID, err)
+		}
+	}
+	if _, _, _, err := ParseRecordURL("https://airtable.com/appAAAAAAAAAAAAAA/tblBBBBBBBBBBBBBB"); err == nil {
+		t.Errorf("Expected error for a url without a record")
+	}
+}
+
+func TestGetRowFromURL(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("tblTASKSTASKSTASK", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	row, err := watcher.GetRowFromURL("https://airtable.com/" + testAirtableBase + "/tblTASKSTASKSTASK/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.ID != id || row.GetFieldString("State") != "ToDo" {
+		t.Errorf("Incorrect row %v", row)
+	}
+	if _, err := watcher.GetRowFromURL("https://airtable.com/appOTHEROTHEROTHE/tblTASKSTASKSTASK/" + id); err == nil {
+		t.Errorf("Expected error for another base")
+	}
+}
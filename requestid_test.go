@@ -0,0 +1,63 @@
+package airtablewatcher
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// headerTransport records a header of every request
+type headerTransport struct {
+	sync.Mutex
+	base   http.RoundTripper
+	header string
+	values []string
+}
+
+func (h *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.Lock()
+	h.values = append(h.values, req.Header.Get(h.header))
+	h.Unlock()
+	return h.base.RoundTrip(req)
+}
+
+func TestRequestID(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	recorder := &headerTransport{base: watcher.Transport, header: RequestIDHeader}
+	watcher.Transport = recorder
+
+	var actionID string
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		actionID = RequestID(ctx)
+		watcher.GetRowContext(ctx, tableName, row.ID)
+		watcher.SetRowContext(ctx, tableName, row.ID, map[string]interface{}{"State": "Done"})
+	})
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if actionID == "" {
+		t.Fatalf("Action context has no request ID")
+	}
+
+	recorder.Lock()
+	defer recorder.Unlock()
+	tagged := 0
+	for _, value := range recorder.values {
+		if value == actionID {
+			tagged++
+		} else if value != "" {
+			t.Errorf("Unexpected request ID %s", value)
+		}
+	}
+	if tagged < 2 {
+		t.Errorf("Expected the action's requests to carry its ID, got %d", tagged)
+	}
+	// The poll's own list request has no ID
+	if recorder.values[0] != "" {
+		t.Errorf("List request should not have a request ID")
+	}
+}
@@ -0,0 +1,81 @@
+package airtablewatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Result statuses
+const (
+	ResultSucceeded = "Succeeded"
+	ResultFailed    = "Failed"
+)
+
+// ActionResult is the outcome of an action, written back to its row with WriteResult
+type ActionResult struct {
+	// Status such as ResultSucceeded or ResultFailed
+	Status  string
+	Message string
+	// When the action finished, WriteResult fills in the current time if it is zero
+	At time.Time
+	// Numbers worth keeping, such as items processed, written as a JSON object
+	Metrics map[string]float64
+}
+
+// ResultFields names the fields WriteResult writes an ActionResult to, "" leaves that part out
+type ResultFields struct {
+	Status  string
+	Message string
+	At      string
+	Metrics string
+}
+
+// DefaultResultFields are the fields results are written to unless Watcher.ResultFields is changed
+var DefaultResultFields = ResultFields{Status: "ResultStatus", Message: "ResultMessage", At: "ResultAt"}
+
+// ResultFunction is an action that reports its outcome instead of writing it to the row itself
+type ResultFunction func(ctx context.Context, watcher *Watcher, tableName string, airtableRow *Row) ActionResult
+
+// WriteResult Write an action's result to its row in the fields named by ResultFields
+func (t *Watcher) WriteResult(ctx context.Context, tableName, recordID string, result ActionResult) error {
+	if result.At.IsZero() {
+		result.At = time.Now()
+	}
+
+	fields := map[string]interface{}{}
+	if t.ResultFields.Status != "" {
+		fields[t.ResultFields.Status] = result.Status
+	}
+	if t.ResultFields.Message != "" {
+		fields[t.ResultFields.Message] = result.Message
+	}
+	if t.ResultFields.At != "" {
+		fields[t.ResultFields.At] = result.At.UTC().Format(time.RFC3339)
+	}
+	if t.ResultFields.Metrics != "" && len(result.Metrics) > 0 {
+		metrics, err := json.Marshal(result.Metrics)
+		if err != nil {
+			return fmt.Errorf("error encoding metrics: %w", err)
+		}
+		fields[t.ResultFields.Metrics] = string(metrics)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return t.SetRowContext(ctx, tableName, recordID, fields)
+}
+
+// ReportResult Turn a ResultFunction into an ActionFunction that writes its result to the row when it returns.
+// The result is still written if the action was canceled, so the row shows how far it got, and carries the
+// dispatch's RequestID
+func ReportResult(action ResultFunction) ActionFunction {
+	return func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		result := action(ctx, watcher, tableName, row)
+		if err := watcher.WriteResult(WithRequestID(context.Background(), RequestID(ctx)), tableName, row.ID, result); err != nil {
+			watcher.logEvent(Event{Type: EventError, TableName: tableName, RecordID: row.ID, Detail: fmt.Sprintf("error writing result: %s", err)})
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package airtablewatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReportResult(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.ResultFields.Metrics = "ResultMetrics"
+	recorder := &headerTransport{base: watcher.Transport, header: RequestIDHeader}
+	watcher.Transport = recorder
+
+	finished := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var actionID string
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, ReportResult(func(ctx context.Context, watcher *Watcher, tableName string, row *Row) ActionResult {
+		actionID = RequestID(ctx)
+		return ActionResult{Status: ResultFailed, Message: "upstream timed out", At: finished, Metrics: map[string]float64{"items": 3}}
+	}))
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	record := fake.Record("Tasks", id)
+	if record["ResultStatus"] != ResultFailed || record["ResultMessage"] != "upstream timed out" ||
+		record["ResultAt"] != "2020-01-02T03:04:05Z" || record["ResultMetrics"] != `{"items":3}` {
+		t.Errorf("Incorrect result fields %v", record)
+	}
+
+	// The result write is the last request and carries the dispatch's request ID
+	recorder.Lock()
+	defer recorder.Unlock()
+	if last := recorder.values[len(recorder.values)-1]; actionID == "" || last != actionID {
+		t.Errorf("Result write should carry request ID %s, got %s", actionID, last)
+	}
+}
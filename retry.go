@@ -0,0 +1,76 @@
+package airtablewatcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fabioberger/airtable-go"
+)
+
+// Defaults for retries
+const (
+	DefaultRetryBackoff = time.Second
+)
+
+// RetryConfig controls how writes recover from common failures.
+// The zero value does not retry
+type RetryConfig struct {
+	// Retry with typecast enabled when airtable rejects a select option that doesn't exist yet,
+	// this creates the option
+	TypecastOnInvalidOption bool
+	// Number of extra attempts on errors Retryable accepts
+	MaxRetries int
+	// Delay before the first retry, doubled every attempt. Defaults to DefaultRetryBackoff
+	Backoff time.Duration
+	// RetryableFunc decides which errors are worth retrying. Defaults to DefaultRetryable
+	RetryableFunc func(err error) bool
+}
+
+// do Run f, retrying transient errors with backoff
+func (r RetryConfig) do(ctx context.Context, f func() error) error {
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	retryable := r.RetryableFunc
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= r.MaxRetries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// DefaultRetryable Check if an airtable error is transient: a 409 conflict, 429 rate limit or 5xx.
+// Errors like 422 validation failures won't succeed on retry
+func DefaultRetryable(err error) bool {
+	apiErr := airtable.Error{}
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusConflict || apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+}
+
+// isInvalidOptionError Check if airtable rejected a write because a select option does not exist
+func isInvalidOptionError(err error) bool {
+	apiErr := airtable.Error{}
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	return strings.Contains(apiErr.Type, "MULTIPLE_CHOICE") || strings.Contains(strings.ToLower(apiErr.Message), "select option")
+}
@@ -0,0 +1,73 @@
+package airtablewatcher
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSetRowRetry(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "New"})
+	watcher := newFakeWatcher(t, fake)
+
+	// No retries by default
+	fake.FailNext(http.StatusConflict, "CONFLICT")
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"State": "ToDo"}); err == nil {
+		t.Errorf("Expected conflict error without retries")
+	}
+
+	// Transient errors are retried
+	watcher.Retry = RetryConfig{MaxRetries: 2, Backoff: time.Millisecond}
+	fake.FailNext(http.StatusConflict, "CONFLICT")
+	fake.FailNext(http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE")
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"State": "ToDo"}); err != nil {
+		t.Errorf("Expected retries to succeed: %s", err)
+	}
+
+	// Validation errors are not
+	fake.FailNext(http.StatusUnprocessableEntity, "INVALID_VALUE_FOR_COLUMN")
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"State": "ToDo"}); err == nil {
+		t.Errorf("Expected validation error")
+	}
+
+	// Invalid select options retry with typecast
+	watcher.Retry.TypecastOnInvalidOption = true
+	fake.FailNext(http.StatusUnprocessableEntity, "INVALID_MULTIPLE_CHOICE_OPTIONS")
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"State": "Brand New Option"}); err != nil {
+		t.Errorf("Expected typecast retry to succeed: %s", err)
+	}
+	if fake.LastBody()["typecast"] != true {
+		t.Errorf("Retry did not use typecast")
+	}
+}
+
+func TestRetryableFunc(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "New"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.AirtableClient.ShouldRetryIfRateLimited = false
+
+	// Rate limits are retried by default
+	watcher.Retry = RetryConfig{MaxRetries: 1, Backoff: time.Millisecond}
+	fake.FailNext(http.StatusTooManyRequests, "TOO_MANY_REQUESTS")
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"State": "ToDo"}); err != nil {
+		t.Errorf("Expected rate limit to be retried: %s", err)
+	}
+
+	// A custom classifier can refuse errors the default would retry
+	calls := 0
+	watcher.Retry.RetryableFunc = func(err error) bool {
+		calls++
+		return false
+	}
+	fake.FailNext(http.StatusInternalServerError, "SERVER_ERROR")
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"State": "ToDo"}); err == nil {
+		t.Errorf("Expected server error without retry")
+	}
+	if calls != 1 {
+		t.Errorf("Expected classifier to be called once, got %d", calls)
+	}
+}
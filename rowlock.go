@@ -0,0 +1,39 @@
+package airtablewatcher
+
+import "sync"
+
+// rowLock serializes writes to a record, refs counts the writers holding or waiting for it
+type rowLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockRow Wait for exclusive write access to a record, returning a function that releases it.
+// Does nothing unless SerializeRowWrites is set
+func (t *Watcher) lockRow(tableName, recordID string) func() {
+	if !t.SerializeRowWrites {
+		return func() {}
+	}
+
+	key := tableName + "/" + recordID
+	t.Lock()
+	lock, ok := t.rowLocks[key]
+	if !ok {
+		lock = &rowLock{}
+		t.rowLocks[key] = lock
+	}
+	lock.refs++
+	t.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		// Forget the lock once nobody needs it
+		t.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(t.rowLocks, key)
+		}
+		t.Unlock()
+	}
+}
@@ -0,0 +1,38 @@
+package airtablewatcher
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSerializeRowWrites(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"Meta": "{}"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.SerializeRowWrites = true
+
+	// Concurrent read-modify-writes of the same row would lose keys without the lock
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := watcher.MergeJSONField("Tasks", id, "Meta", map[string]interface{}{strconv.Itoa(i): true}); err != nil {
+				t.Errorf(err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	meta := map[string]interface{}{}
+	json.Unmarshal([]byte(fake.Record("Tasks", id)["Meta"].(string)), &meta)
+	if len(meta) != 20 {
+		t.Errorf("Expected 20 keys, got %d", len(meta))
+	}
+	if len(watcher.rowLocks) != 0 {
+		t.Errorf("Row locks were not released")
+	}
+}
@@ -1,42 +1,273 @@
 package airtablewatcher
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+
+	"github.com/fabioberger/airtable-go"
 )
 
 // Defaults
 const (
 	AirtableDateFormat = "2006-01-02T15:04:05.000Z"
+	// AirtableDayFormat is the format of date fields without a time
+	AirtableDayFormat = "2006-01-02"
 )
 
 // More defaults
 var (
 	DefaultBlankTime = time.Date(1, 0, 0, 0, 0, 0, 0, time.UTC)
+	// Field names Row.PrimaryField guesses are the primary field, in order
+	DefaultPrimaryFieldNames = []string{"Name", "Title"}
 )
 
+// DefaultFloatFormatter Render whole numbers without a decimal ("12") and anything else with %f ("1.500000")
+func DefaultFloatFormatter(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return fmt.Sprintf("%f", value)
+}
+
+// NumberFormat controls how a watcher's rows convert between numbers and strings
+type NumberFormat struct {
+	// Formatter formats number fields for GetFieldString, DefaultFloatFormatter if nil.
+	// Replace it to match how your base displays numbers
+	Formatter func(float64) string
+	// DecimalSeparator is the decimal separator of numbers formatted as strings (cellFormat=string), "." if empty.
+	// Set it to "," for bases showing numbers like "1.234,5"
+	DecimalSeparator string
+}
+
+// formatFloat Format a number field, a nil format uses the defaults
+func (f *NumberFormat) formatFloat(value float64) string {
+	if f == nil || f.Formatter == nil {
+		return DefaultFloatFormatter(value)
+	}
+	return f.Formatter(value)
+}
+
+// decimalSeparator Get the decimal separator GetFieldFloat parses, a nil format uses "."
+func (f *NumberFormat) decimalSeparator() string {
+	if f == nil || f.DecimalSeparator == "" {
+		return "."
+	}
+	return f.DecimalSeparator
+}
+
 // Row Generic row from airtable
 type Row struct {
 	ID     string
 	Fields interface{}
+	// When the record was created, DefaultBlankTime if unknown
+	CreatedTime time.Time
+
+	commentCount   int
+	lastModified   time.Time
+	lastModifiedBy *Collaborator
+	// The NumberFormat of the watcher the row came from, nil for the defaults
+	numberFormat *NumberFormat
+}
+
+// Collaborator is an airtable user, as found in user fields and record metadata
+type Collaborator struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+// rowEnvelope is the record envelope airtable returns
+type rowEnvelope struct {
+	ID          string      `json:"id"`
+	Fields      interface{} `json:"fields"`
+	CreatedTime string      `json:"createdTime"`
+	// Only present when requested with recordMetadata
+	CommentCount int `json:"commentCount"`
+	// Only present where airtable includes them
+	LastModifiedTime string        `json:"lastModifiedTime"`
+	LastModifiedBy   *Collaborator `json:"lastModifiedBy"`
+}
+
+// UnmarshalJSON decodes a row from the airtable record envelope ({"id": ..., "fields": ...})
+func (r *Row) UnmarshalJSON(data []byte) error {
+	envelope := rowEnvelope{}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("error decoding row: %w", err)
+	}
+	r.ID = envelope.ID
+	r.Fields = envelope.Fields
+	r.commentCount = envelope.CommentCount
+	r.CreatedTime = DefaultBlankTime
+	if createdTime, err := time.Parse(time.RFC3339, envelope.CreatedTime); err == nil {
+		r.CreatedTime = createdTime
+	}
+	r.lastModified = DefaultBlankTime
+	if lastModified, err := time.Parse(time.RFC3339, envelope.LastModifiedTime); err == nil {
+		r.lastModified = lastModified
+	}
+	r.lastModifiedBy = envelope.LastModifiedBy
+	return nil
+}
+
+// MarshalJSON encodes a row as the airtable record envelope ({"id": ..., "createdTime": ..., "fields": ...}),
+// so rows round trip through UnmarshalJSON. Fields are in sorted order, making the output stable to diff
+func (r Row) MarshalJSON() ([]byte, error) {
+	envelope := struct {
+		ID          string      `json:"id"`
+		CreatedTime string      `json:"createdTime,omitempty"`
+		Fields      interface{} `json:"fields"`
+	}{ID: r.ID, Fields: r.Fields}
+	if !r.CreatedTime.IsZero() && !r.CreatedTime.Equal(DefaultBlankTime) {
+		envelope.CreatedTime = r.CreatedTime.UTC().Format(time.RFC3339)
+	}
+	if envelope.Fields == nil {
+		envelope.Fields = map[string]interface{}{}
+	}
+	return json.Marshal(envelope)
+}
+
+// String renders the row's ID followed by its fields as JSON in sorted order, for logs
+func (r *Row) String() string {
+	fields, err := json.Marshal(r.Fields)
+	if err != nil || r.Fields == nil {
+		fields = []byte("{}")
+	}
+	return r.ID + " " + string(fields)
+}
+
+// LastModified Get when the row was last changed, from the record's metadata.
+// DefaultBlankTime if airtable didn't include it, use a "Last modified time" field with GetFieldTime then
+func (r *Row) LastModified() time.Time {
+	if r.lastModified.IsZero() {
+		return DefaultBlankTime
+	}
+	return r.lastModified
+}
+
+// LastModifiedBy Get who last changed the row, from the record's metadata. nil if airtable didn't include it
+func (r *Row) LastModifiedBy() *Collaborator {
+	return r.lastModifiedBy
+}
+
+// CommentCount Get the number of comments on the row.
+// Only known when the rows were fetched with comment counts (Watcher.IncludeCommentCount or ListOptions.CommentCount), 0 otherwise
+func (r *Row) CommentCount() int {
+	return r.commentCount
+}
+
+// FieldError is the value of a formula field that failed to compute, e.g. "#ERROR!".
+// GetField returns it in place of the {"error": ...} object airtable sends
+type FieldError struct {
+	Code string
+}
+
+// Error implements error
+func (e FieldError) Error() string {
+	return e.Code
 }
 
-// GetField Get a generic field value from a row, returns nil if not found
+// GetField Get a generic field value from a row, returns nil if not found.
+// Error cells are returned as a FieldError
 func (r *Row) GetField(fieldName string) interface{} {
 	// Attempt to cast and get state
 	if res, ok := r.Fields.(map[string]interface{}); ok {
 		if state, ok := res[fieldName]; ok {
+			if fieldErr, ok := asFieldError(state); ok {
+				return fieldErr
+			}
 			return state
 		}
 	}
 	return nil
 }
 
+// asFieldError Check if a value is an error cell, {"error": "#ERROR!"}, or a number airtable can't represent, {"specialValue": "NaN"}
+func asFieldError(value interface{}) (FieldError, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok || len(object) != 1 {
+		return FieldError{}, false
+	}
+	if code, ok := object["error"].(string); ok {
+		return FieldError{Code: code}, true
+	}
+	if code, ok := object["specialValue"].(string); ok {
+		return FieldError{Code: code}, true
+	}
+	return FieldError{}, false
+}
+
+// GetFieldAny Get a field's value exactly as decoded from airtable's JSON, nil if not found.
+// This is the escape hatch for any field shape the typed getters don't cover: strings, float64, bool,
+// []interface{} and map[string]interface{}, nested to any depth. Unlike GetField error cells are left as objects
+func (r *Row) GetFieldAny(fieldName string) interface{} {
+	if fields, ok := r.Fields.(map[string]interface{}); ok {
+		return fields[fieldName]
+	}
+	return nil
+}
+
+// FieldNames Get the names of the fields the row has, sorted.
+// Airtable leaves empty fields out, so this is the populated fields, not the table's schema
+func (r *Row) FieldNames() []string {
+	fields, _ := r.Fields.(map[string]interface{})
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetFieldSlice Get a list field value, such as a rollup or formula returning an array.
+// Returns false if the field is missing or not a list
+func (r *Row) GetFieldSlice(fieldName string) ([]interface{}, bool) {
+	value, ok := r.GetFieldAny(fieldName).([]interface{})
+	return value, ok
+}
+
+// GetFieldMap Get an object field value, such as a user or button field.
+// Returns false if the field is missing or not an object
+func (r *Row) GetFieldMap(fieldName string) (map[string]interface{}, bool) {
+	value, ok := r.GetFieldAny(fieldName).(map[string]interface{})
+	return value, ok
+}
+
+// FieldIsError Check if a field is an error cell, such as a formula that failed with #ERROR!.
+// GetFieldString returns the error code, so RegisterFunction can also trigger on "#ERROR!"
+func (r *Row) FieldIsError(fieldName string) bool {
+	_, ok := r.GetField(fieldName).(FieldError)
+	return ok
+}
+
+// PrimaryField Guess the row's primary field without schema knowledge, returning the first of
+// DefaultPrimaryFieldNames the row has. Returns "", nil if none are present.
+// Use Watcher.GetPrimary to look it up from the table's schema instead
+func (r *Row) PrimaryField() (string, interface{}) {
+	for _, name := range DefaultPrimaryFieldNames {
+		if value := r.GetField(name); value != nil {
+			return name, value
+		}
+	}
+	return "", nil
+}
+
 // GetFieldString Get string value from a row
 func (r *Row) GetFieldString(fieldName string) string {
-	// Attempt to cast and get state
-	value := r.GetField(fieldName)
+	return fieldString(r.GetField(fieldName), r.numberFormat)
+}
+
+// fieldString Get the string form of a field value, formatting numbers with format
+func fieldString(value interface{}, format *NumberFormat) string {
 	if valueString, ok := value.(string); ok {
 		return valueString
 	}
@@ -47,7 +278,16 @@ func (r *Row) GetFieldString(fieldName string) string {
 		return "false"
 	}
 	if valueFloat, ok := value.(float64); ok {
-		return fmt.Sprintf("%f", valueFloat)
+		return format.formatFloat(valueFloat)
+	}
+	if fieldErr, ok := value.(FieldError); ok {
+		return fieldErr.Code
+	}
+	// Select options can come back as {id, name, color} objects
+	if valueMap, ok := value.(map[string]interface{}); ok {
+		if name, ok := valueMap["name"].(string); ok {
+			return name
+		}
 	}
 	if value == nil {
 		return ""
@@ -56,6 +296,152 @@ func (r *Row) GetFieldString(fieldName string) string {
 	return fmt.Sprintf("%s", value)
 }
 
+// GetFieldStringSlice Get the values of a list field such as a multiple select or lookup as strings.
+// Single values return a slice of one, a missing field returns nil
+func (r *Row) GetFieldStringSlice(fieldName string) []string {
+	value := r.GetField(fieldName)
+	if value == nil {
+		return nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return []string{fieldString(value, r.numberFormat)}
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		values = append(values, fieldString(item, r.numberFormat))
+	}
+	return values
+}
+
+// GetFieldLinkedNames Get the names of linked records from a lookup field of their primary field,
+// avoiding fetching the linked records. Nested lookups are flattened and blank names dropped.
+// Link fields themselves only contain record IDs
+func (r *Row) GetFieldLinkedNames(fieldName string) []string {
+	names := []string{}
+	var collect func(value interface{})
+	collect = func(value interface{}) {
+		if list, ok := value.([]interface{}); ok {
+			for _, item := range list {
+				collect(item)
+			}
+			return
+		}
+		if name := fieldString(value, r.numberFormat); name != "" {
+			names = append(names, name)
+		}
+	}
+	collect(r.GetField(fieldName))
+	return names
+}
+
+// GetFieldFloat Get a numeric field value from a row.
+// Numbers stored as strings are parsed, including grouped and currency formatted ones like "$1,234.50" (see
+// NumberFormat.DecimalSeparator), booleans are 1 or 0
+func (r *Row) GetFieldFloat(fieldName string) (float64, error) {
+	switch value := r.GetField(fieldName).(type) {
+	case float64:
+		return value, nil
+	case json.Number:
+		return value.Float64()
+	case bool:
+		if value {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		f, err := parseNumber(value, r.numberFormat.decimalSeparator())
+		if err != nil {
+			return 0, fmt.Errorf("field %s is not a number: %w", fieldName, err)
+		}
+		return f, nil
+	case nil:
+		return 0, fmt.Errorf("field %s not found", fieldName)
+	case FieldError:
+		return 0, fmt.Errorf("field %s is an error cell: %w", fieldName, value)
+	default:
+		return 0, fmt.Errorf("field %s is not a number", fieldName)
+	}
+}
+
+// parseNumber Parse a number, allowing the formatting airtable adds with cellFormat=string:
+// grouping separators ("1,234.5"), currency symbols ("$12.50") and percentages ("50%" is 0.5)
+func parseNumber(value, decimalSeparator string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f, nil
+	}
+
+	percent := strings.HasSuffix(value, "%")
+	// Currency symbols or codes either side, e.g. "$12.50" or "12,50 EUR"
+	value = strings.TrimFunc(strings.TrimSuffix(value, "%"), func(c rune) bool {
+		return unicode.IsLetter(c) || unicode.IsSpace(c) || unicode.Is(unicode.Sc, c)
+	})
+	cleaned := strings.Builder{}
+	for _, c := range value {
+		switch {
+		case string(c) == decimalSeparator:
+			cleaned.WriteByte('.')
+		case c >= '0' && c <= '9', c == '-', c == '+', c == 'e', c == 'E':
+			cleaned.WriteRune(c)
+		case c == ',', c == '.', c == '\'', c == '_', unicode.IsSpace(c), c == '\u202f':
+			// Grouping separator
+		case unicode.Is(unicode.Sc, c):
+			// Currency symbol after the sign, e.g. "-$5"
+		default:
+			return 0, fmt.Errorf("unexpected %q in %q", c, value)
+		}
+	}
+	f, err := strconv.ParseFloat(cleaned.String(), 64)
+	if err != nil {
+		return 0, err
+	}
+	if percent {
+		f /= 100
+	}
+	return f, nil
+}
+
+// GetFieldInt Get a whole number field value from a row, such as a rating (1-5 stars) or count field.
+// Errors if the value has a fractional part
+func (r *Row) GetFieldInt(fieldName string) (int, error) {
+	value, err := r.GetFieldFloat(fieldName)
+	if err != nil {
+		return 0, err
+	}
+	if value != math.Trunc(value) {
+		return 0, fmt.Errorf("field %s is not a whole number: %v", fieldName, value)
+	}
+	return int(value), nil
+}
+
+// GetFieldPercent Get a percent field value from a row.
+// Airtable stores percents as fractions, so 25% returns 0.25 and the 0-100 form 25
+func (r *Row) GetFieldPercent(fieldName string) (fraction float64, percent float64, err error) {
+	fraction, err = r.GetFieldFloat(fieldName)
+	if err != nil {
+		return 0, 0, err
+	}
+	return fraction, fraction * 100, nil
+}
+
+// GetFieldDuration Get a duration field value from a row.
+// Numbers are seconds, as airtable returns duration fields, strings are parsed with time.ParseDuration (e.g. "90m")
+func (r *Row) GetFieldDuration(fieldName string) (time.Duration, error) {
+	if value, ok := r.GetField(fieldName).(string); ok {
+		duration, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("field %s is not a duration: %w", fieldName, err)
+		}
+		return duration, nil
+	}
+	seconds, err := r.GetFieldFloat(fieldName)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 // GetFieldAttachments Gets the attachments from a field
 func (r *Row) GetFieldAttachments(fieldName string) ([]AirtableAttachment, error) {
 	value := r.GetField(fieldName)
@@ -74,44 +460,300 @@ func (r *Row) GetFieldAttachments(fieldName string) ([]AirtableAttachment, error
 	return attachments, nil
 }
 
-// GetFieldTime Get a field value in time format from a row, returns DefaultBlankTime if parsing fails
+// GetFieldTime Get a field value in time format from a row, returns DefaultBlankTime if parsing fails.
+// Date fields without a time are midnight UTC
 func (r *Row) GetFieldTime(fieldName string) time.Time {
 	// Attempt to cast and get state
-	// TODO: Support date field without time
 	timeStr := r.GetFieldString(fieldName)
 	if timeStr == "" {
 		return DefaultBlankTime
 	}
-	time, err := time.Parse(AirtableDateFormat, timeStr)
-	if err == nil {
-		return time
+	for _, format := range []string{AirtableDateFormat, AirtableDayFormat} {
+		if parsed, err := time.Parse(format, timeStr); err == nil {
+			return parsed
+		}
 	}
 	return DefaultBlankTime
 }
 
-// GetRows Get list of tasks in airtable
+// GetFieldDate Get a date field value as midnight UTC on that day, returns DefaultBlankTime if parsing fails.
+// Date and time fields are cut down to their day in UTC
+func (r *Row) GetFieldDate(fieldName string) time.Time {
+	value := r.GetFieldTime(fieldName)
+	if value.Equal(DefaultBlankTime) {
+		return value
+	}
+	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// GetRows Get list of tasks in airtable.
+// While the watcher is running, results are reused for the rest of the poll cycle, see InvalidateTable
 func (t *Watcher) GetRows(tableName string) ([]Row, error) {
-	tasks := []Row{}
-	err := t.AirtableClient.ListRecords(tableName, &tasks)
+	return t.GetRowsWithOptions(tableName, ListOptions{CommentCount: t.IncludeCommentCount})
+}
+
+// GetRowsMulti Get the rows of several tables concurrently, keyed by table name.
+// Requests still respect RequestsPerSecond
+func (t *Watcher) GetRowsMulti(tableNames []string) (map[string][]Row, error) {
+	allRows, errs := t.getRowsMulti(tableNames)
+	if err := firstTableError(tableNames, errs); err != nil {
+		return nil, err
+	}
+	return allRows, nil
+}
+
+// getRowsMulti Get the rows of several tables concurrently, along with the error for each table that failed
+func (t *Watcher) getRowsMulti(tableNames []string) (map[string][]Row, map[string]error) {
+	type result struct {
+		tableName string
+		rows      []Row
+		err       error
+	}
+	results := make(chan result, len(tableNames))
+	for _, tableName := range tableNames {
+		go func(tableName string) {
+			rows, err := t.GetRows(tableName)
+			results <- result{tableName, rows, err}
+		}(tableName)
+	}
+
+	allRows := map[string][]Row{}
+	errs := map[string]error{}
+	for range tableNames {
+		result := <-results
+		if result.err != nil {
+			errs[result.tableName] = result.err
+			continue
+		}
+		allRows[result.tableName] = result.rows
+	}
+
+	return allRows, errs
+}
+
+// firstTableError Get the error of the first table in tableNames that failed, nil if none did
+func firstTableError(tableNames []string, errs map[string]error) error {
+	for _, tableName := range tableNames {
+		if err, ok := errs[tableName]; ok {
+			return fmt.Errorf("error getting rows of %s: %w", tableName, err)
+		}
+	}
+	return nil
+}
+
+// GetRowsChanged Get rows whose fields changed (or that are new) since the last call for this table.
+// The first call for a table returns every row.
+func (t *Watcher) GetRowsChanged(tableName string) ([]Row, error) {
+	rows, err := t.GetRows(tableName)
 	if err != nil {
 		return nil, err
 	}
 
-	return tasks, nil
+	fingerprints := map[string]string{}
+	for _, row := range rows {
+		fingerprint, err := row.fingerprint()
+		if err != nil {
+			return nil, err
+		}
+		fingerprints[row.ID] = fingerprint
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	previous := t.rowFingerprints[tableName]
+	changed := []Row{}
+	for _, row := range rows {
+		if fingerprint, ok := previous[row.ID]; !ok || fingerprint != fingerprints[row.ID] {
+			changed = append(changed, row)
+		}
+	}
+	// Replace the map so deleted rows are forgotten
+	t.rowFingerprints[tableName] = fingerprints
+
+	return changed, nil
+}
+
+// fingerprint Get a hash of the row's fields
+func (r *Row) fingerprint() (string, error) {
+	// json.Marshal sorts map keys so this is stable
+	JSON, err := json.Marshal(r.Fields)
+	if err != nil {
+		return "", fmt.Errorf("error fingerprinting row: %w", err)
+	}
+	hash := fnv.New64a()
+	hash.Write(JSON)
+	return fmt.Sprintf("%x", hash.Sum64()), nil
+}
+
+// rowsByID Index rows by record ID
+func rowsByID(rows []Row) map[string]Row {
+	byID := make(map[string]Row, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+	return byID
 }
 
 // GetRow Get airtable row
 func (t *Watcher) GetRow(tableName, recordID string) (*Row, error) {
-	row := &Row{}
-	err := t.AirtableClient.RetrieveRecord(tableName, recordID, row)
+	return t.GetRowContext(context.Background(), tableName, recordID)
+}
+
+// GetRowContext Get airtable row, the request is canceled with ctx and carries its RequestID
+func (t *Watcher) GetRowContext(ctx context.Context, tableName, recordID string) (*Row, error) {
+	rawBody, err := t.request(ctx, "GET", t.tablePath(tableName, recordID), nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	row := &Row{}
+	if err := json.Unmarshal(rawBody, row); err != nil {
+		return nil, err
+	}
+	row.numberFormat = &t.NumberFormat
+
 	return row, nil
 }
 
-// SetRow Set provided fields for a row
+// GetRawRecord Get the raw JSON airtable returns for a record, envelope and all.
+// Useful for debugging and for field types GetField does not handle
+func (t *Watcher) GetRawRecord(tableName, recordID string) (json.RawMessage, error) {
+	rawBody, err := t.request(context.Background(), "GET", t.tablePath(tableName, recordID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(rawBody), nil
+}
+
+// SetRowDate Set a date field without a time to the day of date, in date's own time zone
+func (t *Watcher) SetRowDate(tableName, recordID, fieldName string, date time.Time) error {
+	return t.SetRow(tableName, recordID, map[string]interface{}{fieldName: date.Format(AirtableDayFormat)})
+}
+
+// SetRow Set provided fields for a row.
+// Failures are retried according to t.Retry
 func (t *Watcher) SetRow(tableName, recordID string, fields map[string]interface{}) error {
-	return t.AirtableClient.UpdateRecord(tableName, recordID, fields, nil)
+	return t.SetRowContext(context.Background(), tableName, recordID, fields)
+}
+
+// SetRowContext Set provided fields for a row, requests are canceled with ctx and carry its RequestID.
+// Failures are retried according to t.Retry
+func (t *Watcher) SetRowContext(ctx context.Context, tableName, recordID string, fields map[string]interface{}) error {
+	if t.SkipEmptyWrites {
+		fields = withoutEmpty(fields)
+		if len(fields) == 0 {
+			return nil
+		}
+	}
+	if t.StrictFields {
+		if err := t.checkFields(tableName, fields); err != nil {
+			return err
+		}
+	}
+
+	defer t.lockRow(tableName, recordID)()
+	return t.setRow(ctx, tableName, recordID, fields)
+}
+
+// CreateRow Create a row in a table, returning it with its new ID and fields as airtable stored them.
+// The table's CreateDefaults are filled in under fields. Creates are not retried, see createRecords
+func (t *Watcher) CreateRow(tableName string, fields map[string]interface{}) (*Row, error) {
+	return t.CreateRowContext(context.Background(), tableName, fields)
+}
+
+// CreateRowContext Create a row like CreateRow, requests are canceled with ctx and carry its RequestID
+func (t *Watcher) CreateRowContext(ctx context.Context, tableName string, fields map[string]interface{}) (*Row, error) {
+	fields = t.withCreateDefaults(tableName, fields)
+	if t.StrictFields {
+		if err := t.checkFields(tableName, fields); err != nil {
+			return nil, err
+		}
+	}
+
+	created, err := t.createRecords(ctx, tableName, []map[string]interface{}{fields})
+	if err != nil {
+		return nil, err
+	}
+	if len(created) != 1 {
+		return nil, fmt.Errorf("expected 1 created record in %s, got %d", tableName, len(created))
+	}
+	return &created[0], nil
+}
+
+// ErrRowNotFound is returned when deleting a row that does not exist
+var ErrRowNotFound = errors.New("row not found")
+
+// DeleteRow Delete a row, returning an error wrapping ErrRowNotFound if there is no such row.
+// Failures are retried according to t.Retry, a retry finding the row gone counts as deleted
+func (t *Watcher) DeleteRow(tableName, recordID string) error {
+	return t.DeleteRowContext(context.Background(), tableName, recordID)
+}
+
+// DeleteRowContext Delete a row like DeleteRow, requests are canceled with ctx and carry its RequestID
+func (t *Watcher) DeleteRowContext(ctx context.Context, tableName, recordID string) error {
+	defer t.lockRow(tableName, recordID)()
+	defer t.InvalidateTable(tableName)
+
+	attempts := 0
+	err := t.Retry.do(ctx, func() error {
+		attempts++
+		_, err := t.request(ctx, "DELETE", t.tablePath(tableName, recordID), nil, nil)
+		// A failed attempt may still have deleted the row
+		if attempts > 1 && isNotFoundError(err) {
+			return nil
+		}
+		return err
+	})
+	if isNotFoundError(err) {
+		return fmt.Errorf("%w: %s in table %s", ErrRowNotFound, recordID, tableName)
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting row %s: %w", recordID, err)
+	}
+	return nil
+}
+
+// isNotFoundError Check if airtable answered 404
+func isNotFoundError(err error) bool {
+	apiErr := airtable.Error{}
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// setRow Write fields to a row, retrying according to t.Retry. Callers hold the row's lock
+func (t *Watcher) setRow(ctx context.Context, tableName, recordID string, fields map[string]interface{}) error {
+	defer t.InvalidateTable(tableName)
+
+	err := t.Retry.do(ctx, func() error {
+		return t.updateRecord(ctx, tableName, recordID, fields, false)
+	})
+	if err != nil && t.Retry.TypecastOnInvalidOption && isInvalidOptionError(err) {
+		err = t.Retry.do(ctx, func() error {
+			return t.updateRecord(ctx, tableName, recordID, fields, true)
+		})
+	}
+
+	return err
+}
+
+// withoutEmpty Copy fields without "" and nil values
+func withoutEmpty(fields map[string]interface{}) map[string]interface{} {
+	kept := make(map[string]interface{}, len(fields))
+	for name, value := range fields {
+		if value == nil || value == "" {
+			continue
+		}
+		kept[name] = value
+	}
+	return kept
+}
+
+// updateRecord PATCH the fields of a record
+func (t *Watcher) updateRecord(ctx context.Context, tableName, recordID string, fields map[string]interface{}, typecast bool) error {
+	body := map[string]interface{}{"fields": fields}
+	if typecast {
+		body["typecast"] = true
+	}
+	_, err := t.request(ctx, "PATCH", t.tablePath(tableName, recordID), nil, body)
+	return err
 }
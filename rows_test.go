@@ -0,0 +1,569 @@
+package airtablewatcher
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetRowsChanged(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	first := fake.AddRecord("Tasks", map[string]interface{}{"State": "New"})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "New"})
+	watcher := newFakeWatcher(t, fake)
+
+	// First call gets everything
+	rows, err := watcher.GetRowsChanged("Tasks")
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if len(rows) != 2 {
+		t.Errorf("Expected all rows on first call, got %d", len(rows))
+	}
+
+	// Nothing changed
+	rows, _ = watcher.GetRowsChanged("Tasks")
+	if len(rows) != 0 {
+		t.Errorf("Expected no changed rows, got %d", len(rows))
+	}
+
+	// Change one row and add another
+	fake.SetField("Tasks", first, "State", "ToDo")
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "New"})
+	rows, _ = watcher.GetRowsChanged("Tasks")
+	if len(rows) != 2 {
+		t.Errorf("Expected 2 changed rows, got %d", len(rows))
+	}
+}
+
+func TestRowUnmarshalJSON(t *testing.T) {
+	response := `{"records": [
+		{"id": "recAAAAAAAAAAAAAA", "createdTime": "2020-01-02T03:04:05.000Z", "fields": {"Name": "First", "State": "ToDo"}},
+		{"id": "recBBBBBBBBBBBBBB", "createdTime": "2020-01-02T03:04:05.000Z", "fields": {}}
+	]}`
+	records := struct {
+		Records []Row `json:"records"`
+	}{}
+	if err := json.Unmarshal([]byte(response), &records); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if len(records.Records) != 2 {
+		t.Errorf("Expected 2 rows, got %d", len(records.Records))
+		return
+	}
+	if records.Records[0].ID != "recAAAAAAAAAAAAAA" || records.Records[1].ID != "recBBBBBBBBBBBBBB" {
+		t.Errorf("ID not populated")
+	}
+	if records.Records[0].GetFieldString("State") != "ToDo" {
+		t.Errorf("Fields not populated")
+	}
+
+	// Through the client as well
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	rows, err := watcher.GetRows("Tasks")
+	if err != nil || len(rows) != 1 || rows[0].ID != id {
+		t.Errorf("ID not populated from ListRecords")
+	}
+	row, err := watcher.GetRow("Tasks", id)
+	if err != nil || row.ID != id {
+		t.Errorf("ID not populated from RetrieveRecord")
+	}
+}
+
+func TestGetRawRecord(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	raw, err := watcher.GetRawRecord("Tasks", id)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &record); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if record["id"] != id || record["fields"] == nil {
+		t.Errorf("Raw record missing envelope: %s", raw)
+	}
+
+	// Missing records should error
+	if _, err := watcher.GetRawRecord("Tasks", "recMISSINGMISSING"); err == nil {
+		t.Errorf("Expected error for missing record")
+	}
+}
+
+func TestGetRowsMulti(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.AddRecord("Other", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	allRows, err := watcher.GetRowsMulti([]string{"Tasks", "Other", "Empty"})
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if len(allRows["Tasks"]) != 2 || len(allRows["Other"]) != 1 || len(allRows["Empty"]) != 0 {
+		t.Errorf("Incorrect rows: %v", allRows)
+	}
+}
+
+func TestGetFieldFloat(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{"Float": 1.5, "String": " 2.5 ", "Bool": true, "Text": "abc"}}
+	if value, err := row.GetFieldFloat("Float"); err != nil || value != 1.5 {
+		t.Errorf("Incorrect float %v %v", value, err)
+	}
+	if value, err := row.GetFieldFloat("String"); err != nil || value != 2.5 {
+		t.Errorf("Incorrect string float %v %v", value, err)
+	}
+	if value, err := row.GetFieldFloat("Bool"); err != nil || value != 1 {
+		t.Errorf("Incorrect bool float %v %v", value, err)
+	}
+	if _, err := row.GetFieldFloat("Text"); err == nil {
+		t.Errorf("Expected error for text")
+	}
+	if _, err := row.GetFieldFloat("Missing"); err == nil {
+		t.Errorf("Expected error for missing field")
+	}
+}
+
+func TestGetFieldFloatFormatted(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{
+		"Grouped": "1,234.5", "Currency": "$1,234", "Negative": "-$5.25", "Code": "12 EUR",
+		"Percent": "50%", "Count": "1,000", "Local": "1.234,5", "Words": "twelve",
+	}}
+	for field, expected := range map[string]float64{"Grouped": 1234.5, "Currency": 1234, "Negative": -5.25, "Code": 12, "Percent": 0.5} {
+		if value, err := row.GetFieldFloat(field); err != nil || value != expected {
+			t.Errorf("Incorrect %s float %v %v", field, value, err)
+		}
+	}
+	if value, err := row.GetFieldInt("Count"); err != nil || value != 1000 {
+		t.Errorf("Incorrect grouped int %v %v", value, err)
+	}
+	if _, err := row.GetFieldFloat("Words"); err == nil {
+		t.Errorf("Expected error for words")
+	}
+
+	row.numberFormat = &NumberFormat{DecimalSeparator: ","}
+	if value, err := row.GetFieldFloat("Local"); err != nil || value != 1234.5 {
+		t.Errorf("Incorrect local float %v %v", value, err)
+	}
+}
+
+func TestGetFieldPercentAndRating(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{"Done": 0.25, "Rating": 4.0, "Float": 1.5}}
+	if fraction, percent, err := row.GetFieldPercent("Done"); err != nil || fraction != 0.25 || percent != 25 {
+		t.Errorf("Incorrect percent %v %v %v", fraction, percent, err)
+	}
+	if rating, err := row.GetFieldInt("Rating"); err != nil || rating != 4 {
+		t.Errorf("Incorrect rating %v %v", rating, err)
+	}
+	if _, err := row.GetFieldInt("Float"); err == nil {
+		t.Errorf("Expected error for fractional int")
+	}
+}
+
+func TestCommentCount(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.SetCommentCount("Tasks", id, 3)
+	watcher := newFakeWatcher(t, fake)
+
+	rows, _ := watcher.GetRows("Tasks")
+	if len(rows) != 1 || rows[0].CommentCount() != 0 {
+		t.Errorf("Comment count should not be fetched by default")
+	}
+	watcher.IncludeCommentCount = true
+	rows, _ = watcher.GetRows("Tasks")
+	if len(rows) != 1 || rows[0].CommentCount() != 3 {
+		t.Errorf("Incorrect comment count")
+	}
+}
+
+func TestGetRowsPagination(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.PageSize = 2
+	for i := 0; i < 5; i++ {
+		fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	}
+	watcher := newFakeWatcher(t, fake)
+
+	rows, err := watcher.GetRows("Tasks")
+	if err != nil || len(rows) != 5 {
+		t.Errorf("Expected 5 rows across pages, got %d: %v", len(rows), err)
+	}
+	if fake.ListRequests("Tasks") != 3 {
+		t.Errorf("Expected 3 pages, got %d", fake.ListRequests("Tasks"))
+	}
+}
+
+func TestGetFieldStringFloat(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{"Quantity": 12.0, "Negative": -3.0, "Price": 1.5}}
+	if value := row.GetFieldString("Quantity"); value != "12" {
+		t.Errorf("Whole number rendered as %s", value)
+	}
+	if value := row.GetFieldString("Negative"); value != "-3" {
+		t.Errorf("Negative whole number rendered as %s", value)
+	}
+	if value := row.GetFieldString("Price"); value != "1.500000" {
+		t.Errorf("Fraction rendered as %s", value)
+	}
+
+	// Custom formatting
+	row.numberFormat = &NumberFormat{Formatter: func(value float64) string { return strconv.FormatFloat(value, 'f', 2, 64) }}
+	if value := row.GetFieldString("Price"); value != "1.50" {
+		t.Errorf("Custom formatter not used, got %s", value)
+	}
+}
+
+func TestNumberFormatPerWatcher(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"Price": 1.5, "Local": "1.234,5"})
+	plain := newFakeWatcher(t, fake)
+	formatted := newFakeWatcher(t, fake)
+	formatted.NumberFormat.Formatter = func(value float64) string { return strconv.FormatFloat(value, 'f', 2, 64) }
+	formatted.NumberFormat.DecimalSeparator = ","
+
+	if rows, err := plain.GetRows("Tasks"); err != nil || len(rows) != 1 {
+		t.Fatalf("Error getting rows %v", err)
+	} else if value, _ := rows[0].GetFieldFloat("Local"); value != 1.2345 {
+		t.Errorf("Default separator parsed %v", value)
+	}
+	if rows, err := formatted.GetRows("Tasks"); err != nil || len(rows) != 1 {
+		t.Fatalf("Error getting rows %v", err)
+	} else if value, _ := rows[0].GetFieldFloat("Local"); value != 1234.5 {
+		t.Errorf("Watcher separator parsed %v", value)
+	}
+
+	for watcher, expected := range map[*Watcher]string{plain: "1.500000", formatted: "1.50"} {
+		rows, err := watcher.GetRows("Tasks")
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("Error getting rows %v", err)
+		}
+		if value := rows[0].GetFieldString("Price"); value != expected {
+			t.Errorf("Listed row rendered %s, expected %s", value, expected)
+		}
+		row, err := watcher.GetRow("Tasks", rows[0].ID)
+		if err != nil || row.GetFieldString("Price") != expected {
+			t.Errorf("Fetched row rendered %v, expected %s: %v", row, expected, err)
+		}
+	}
+}
+
+func TestGetFieldStringSingleSelect(t *testing.T) {
+	response := `{"id": "recAAAAAAAAAAAAAA", "fields": {
+		"Plain": "ToDo",
+		"Object": {"id": "selAAAAAAAAAAAAAA", "name": "ToDo", "color": "blueLight2"}
+	}}`
+	row := Row{}
+	if err := json.Unmarshal([]byte(response), &row); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if value := row.GetFieldString("Plain"); value != "ToDo" {
+		t.Errorf("Incorrect string select %q", value)
+	}
+	if value := row.GetFieldString("Object"); value != "ToDo" {
+		t.Errorf("Incorrect object select %q", value)
+	}
+
+	// Triggers match either shape
+	w := watch{fieldName: "Object", triggerValues: []string{"ToDo"}}
+	if !w.triggered(&row, nil) {
+		t.Errorf("Object select did not trigger")
+	}
+}
+
+func TestSkipEmptyWrites(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"Name": "Task", "Notes": "keep", "State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.SkipEmptyWrites = true
+
+	fields := map[string]interface{}{"Name": "", "Notes": nil, "State": "Done"}
+	if err := watcher.SetRow("Tasks", id, fields); err != nil {
+		t.Fatal(err)
+	}
+	record := fake.Record("Tasks", id)
+	if record["Name"] != "Task" || record["Notes"] != "keep" || record["State"] != "Done" {
+		t.Errorf("Empty values were written %v", record)
+	}
+	if len(fields) != 3 {
+		t.Errorf("Caller's fields were modified")
+	}
+
+	// Explicit clears still work when it is off
+	watcher.SkipEmptyWrites = false
+	watcher.SetRow("Tasks", id, map[string]interface{}{"Notes": ""})
+	if record := fake.Record("Tasks", id); record["Notes"] == "keep" {
+		t.Errorf("Field was not cleared")
+	}
+}
+
+func TestGetFieldLinkedNames(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{
+		"Project Names": []interface{}{"Alpha", "Beta"},
+		"Nested":        []interface{}{[]interface{}{"Alpha"}, "", []interface{}{"Gamma"}},
+		"Tags":          []interface{}{"a", map[string]interface{}{"name": "b"}},
+		"Single":        "one",
+	}}
+	if names := row.GetFieldLinkedNames("Project Names"); !reflect.DeepEqual(names, []string{"Alpha", "Beta"}) {
+		t.Errorf("Incorrect linked names %v", names)
+	}
+	if names := row.GetFieldLinkedNames("Nested"); !reflect.DeepEqual(names, []string{"Alpha", "Gamma"}) {
+		t.Errorf("Incorrect nested linked names %v", names)
+	}
+	if values := row.GetFieldStringSlice("Tags"); !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Errorf("Incorrect string slice %v", values)
+	}
+	if values := row.GetFieldStringSlice("Single"); !reflect.DeepEqual(values, []string{"one"}) {
+		t.Errorf("Incorrect single value slice %v", values)
+	}
+	if values := row.GetFieldStringSlice("Missing"); values != nil {
+		t.Errorf("Missing field should be nil, got %v", values)
+	}
+}
+
+func TestGetFieldDuration(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{"Seconds": 90.0, "Text": "1h30m", "Bad": "soon"}}
+	if duration, err := row.GetFieldDuration("Seconds"); err != nil || duration != time.Second*90 {
+		t.Errorf("Incorrect seconds duration %v %v", duration, err)
+	}
+	if duration, err := row.GetFieldDuration("Text"); err != nil || duration != time.Minute*90 {
+		t.Errorf("Incorrect text duration %v %v", duration, err)
+	}
+	if _, err := row.GetFieldDuration("Bad"); err == nil {
+		t.Errorf("Expected error for invalid duration")
+	}
+}
+
+func TestFieldIsError(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{
+		"Total":   map[string]interface{}{"error": "#ERROR!"},
+		"Ratio":   map[string]interface{}{"specialValue": "NaN"},
+		"Working": 3.0,
+	}}
+	if !row.FieldIsError("Total") || !row.FieldIsError("Ratio") || row.FieldIsError("Working") || row.FieldIsError("Missing") {
+		t.Errorf("Incorrect error cell detection")
+	}
+	if value := row.GetFieldString("Total"); value != "#ERROR!" {
+		t.Errorf("Error cell should read as its code, got %q", value)
+	}
+	fieldErr := FieldError{}
+	if _, err := row.GetFieldFloat("Total"); !errors.As(err, &fieldErr) || fieldErr.Code != "#ERROR!" {
+		t.Errorf("Expected FieldError, got %v", err)
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	row := Row{}
+	record := `{"id": "recAAAAAAAAAAAAAA", "fields": {}, "lastModifiedTime": "2020-01-02T03:04:05.000Z",
+		"lastModifiedBy": {"id": "usrAAAAAAAAAAAAAA", "email": "sam@example.com", "name": "Sam"}}`
+	if err := json.Unmarshal([]byte(record), &row); err != nil {
+		t.Fatal(err)
+	}
+	if !row.LastModified().Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Incorrect last modified time %s", row.LastModified())
+	}
+	if by := row.LastModifiedBy(); by == nil || by.Email != "sam@example.com" {
+		t.Errorf("Incorrect last modified by %v", by)
+	}
+
+	// Missing metadata
+	json.Unmarshal([]byte(`{"id": "recAAAAAAAAAAAAAA", "fields": {}}`), &row)
+	if !row.LastModified().Equal(DefaultBlankTime) || row.LastModifiedBy() != nil {
+		t.Errorf("Missing metadata should be blank")
+	}
+}
+
+func TestGetFieldAny(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{
+		"Rollup": []interface{}{1.0, []interface{}{"nested"}},
+		"User":   map[string]interface{}{"id": "usrAAAAAAAAAAAAAA", "name": "Sam"},
+		"Broken": map[string]interface{}{"error": "#ERROR!"},
+	}}
+	if list, ok := row.GetFieldSlice("Rollup"); !ok || len(list) != 2 {
+		t.Errorf("Incorrect slice %v", list)
+	}
+	if _, ok := row.GetFieldSlice("User"); ok {
+		t.Errorf("Object should not be a slice")
+	}
+	if user, ok := row.GetFieldMap("User"); !ok || user["name"] != "Sam" {
+		t.Errorf("Incorrect map %v", user)
+	}
+	if _, ok := row.GetFieldMap("Missing"); ok {
+		t.Errorf("Missing field should not be a map")
+	}
+	if raw, ok := row.GetFieldAny("Broken").(map[string]interface{}); !ok || raw["error"] != "#ERROR!" {
+		t.Errorf("GetFieldAny should return the raw error object")
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{"State": "ToDo", "Name": "a", "Count": 1.0}}
+	if names := row.FieldNames(); !reflect.DeepEqual(names, []string{"Count", "Name", "State"}) {
+		t.Errorf("Incorrect field names %v", names)
+	}
+	if names := (&Row{}).FieldNames(); len(names) != 0 {
+		t.Errorf("Expected no field names, got %v", names)
+	}
+}
+
+func TestFieldDate(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"Due": "2021-03-04", "Started": "2021-03-04T23:30:00.000Z"})
+	watcher := newFakeWatcher(t, fake)
+
+	row, err := watcher.GetRow("Tasks", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	if !row.GetFieldDate("Due").Equal(day) || !row.GetFieldTime("Due").Equal(day) {
+		t.Errorf("Incorrect date %s", row.GetFieldDate("Due"))
+	}
+	if !row.GetFieldDate("Started").Equal(day) {
+		t.Errorf("Incorrect date of a date and time %s", row.GetFieldDate("Started"))
+	}
+	if !row.GetFieldDate("Missing").Equal(DefaultBlankTime) {
+		t.Errorf("Expected blank time for a missing date")
+	}
+
+	// Written in the time's own zone, not shifted to UTC
+	local := time.Date(2021, 5, 6, 1, 0, 0, 0, time.FixedZone("UTC+10", 10*60*60))
+	if err := watcher.SetRowDate("Tasks", id, "Due", local); err != nil {
+		t.Fatal(err)
+	}
+	if due := fake.Record("Tasks", id)["Due"]; due != "2021-05-06" {
+		t.Errorf("Incorrect written date %v", due)
+	}
+}
+
+func TestRowSerialization(t *testing.T) {
+	created := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := &Row{ID: "recAAAAAAAAAAAAAA", CreatedTime: created, Fields: map[string]interface{}{"State": "ToDo", "Count": 2.0}}
+	if s := row.String(); s != `recAAAAAAAAAAAAAA {"Count":2,"State":"ToDo"}` {
+		t.Errorf("Incorrect string %s", s)
+	}
+
+	encoded, err := json.Marshal([]Row{*row})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `[{"id":"recAAAAAAAAAAAAAA","createdTime":"2021-01-02T03:04:05Z","fields":{"Count":2,"State":"ToDo"}}]` {
+		t.Errorf("Incorrect JSON %s", encoded)
+	}
+	decoded := []Row{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded[0].ID != row.ID || !decoded[0].CreatedTime.Equal(created) || !reflect.DeepEqual(decoded[0].Fields, row.Fields) {
+		t.Errorf("Row did not round trip %+v", decoded[0])
+	}
+}
+
+func TestCreateRow(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	watcher := newFakeWatcher(t, fake)
+	watcher.CreateDefaults = map[string]map[string]interface{}{"Results": {"Source": "watcher"}}
+
+	if rows, _ := watcher.GetRows("Results"); len(rows) != 0 {
+		t.Fatalf("Expected no rows, got %v", rows)
+	}
+	row, err := watcher.CreateRow("Results", map[string]interface{}{"Name": "Done", "Count": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.ID == "" || row.GetFieldString("Name") != "Done" || row.GetFieldString("Source") != "watcher" {
+		t.Errorf("Created row should have its ID and fields %v", row)
+	}
+	if count, err := row.GetFieldInt("Count"); err != nil || count != 2 {
+		t.Errorf("Incorrect count %v %v", count, err)
+	}
+	if rows, _ := watcher.GetRows("Results"); len(rows) != 1 || rows[0].ID != row.ID {
+		t.Errorf("Expected the created row to be listed, got %v", rows)
+	}
+}
+
+func TestDeleteRow(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	watcher := newFakeWatcher(t, fake)
+
+	row, err := watcher.CreateRow("Tasks", map[string]interface{}{"State": "Done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.DeleteRow("Tasks", row.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watcher.GetRow("Tasks", row.ID); err == nil {
+		t.Errorf("Expected the deleted row to be gone")
+	}
+
+	err = watcher.DeleteRow("Tasks", row.ID)
+	if !errors.Is(err, ErrRowNotFound) {
+		t.Fatalf("Expected ErrRowNotFound, got %v", err)
+	}
+	if err.Error() != "row not found: "+row.ID+" in table Tasks" {
+		t.Errorf("Error should name the row and table: %s", err)
+	}
+}
+
+// lostResponseTransport sends each DELETE through but answers the first with a 502, as if the response was lost
+type lostResponseTransport struct {
+	base http.RoundTripper
+	lost bool
+}
+
+func (l *lostResponseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := l.base.RoundTrip(req)
+	if err != nil || req.Method != "DELETE" || l.lost {
+		return resp, err
+	}
+	l.lost = true
+	resp.Body.Close()
+	body := `{"error": {"type": "BAD_GATEWAY", "message": "bad gateway"}}`
+	return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway", Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func TestDeleteRowRetried(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.Transport = &lostResponseTransport{base: fake.Transport()}
+	watcher.Retry = RetryConfig{MaxRetries: 2, Backoff: time.Millisecond}
+
+	// The first attempt deleted it, the retry finding it gone is not an error
+	if err := watcher.DeleteRow("Tasks", id); err != nil {
+		t.Errorf("Expected the retried delete to succeed, got %v", err)
+	}
+	if fake.Record("Tasks", id) != nil {
+		t.Errorf("Row was not deleted")
+	}
+}
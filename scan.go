@@ -0,0 +1,94 @@
+package airtablewatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/fabioberger/airtable-go"
+)
+
+// GetRowsPage Get a single page of rows starting at offset ("" for the first page).
+// Returns the offset of the next page, "" if this was the last one
+func (t *Watcher) GetRowsPage(tableName string, options ListOptions, offset string) ([]Row, string, error) {
+	page, err := t.listPage(context.Background(), tableName, options.query(), offset)
+	if err != nil {
+		return nil, "", err
+	}
+	return page.Records, page.Offset, nil
+}
+
+// CursorStore persists the progress of a ScanTable job so it can resume after a restart
+type CursorStore interface {
+	// Save the offset of the next page to process for the job, "" once the job is done
+	Save(job, cursor string) error
+	// Load the saved offset for the job, "" if there is none
+	Load(job string) (string, error)
+}
+
+// MemoryCursorStore is a CursorStore that only lasts as long as the process, useful in tests
+type MemoryCursorStore struct {
+	sync.Mutex
+	cursors map[string]string
+}
+
+// NewMemoryCursorStore Create an empty MemoryCursorStore
+func NewMemoryCursorStore() *MemoryCursorStore {
+	return &MemoryCursorStore{cursors: map[string]string{}}
+}
+
+// Save implements CursorStore
+func (m *MemoryCursorStore) Save(job, cursor string) error {
+	m.Lock()
+	defer m.Unlock()
+	m.cursors[job] = cursor
+	return nil
+}
+
+// Load implements CursorStore
+func (m *MemoryCursorStore) Load(job string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.cursors[job], nil
+}
+
+// ScanTable Process every row of a table a page at a time, saving the cursor to store after each page
+// so a restarted process calling ScanTable with the same job resumes from the next unprocessed page.
+// If processPage fails the scan stops and resumes with that page next time.
+// Airtable offsets expire after a while, in which case the scan starts over from the first page
+func (t *Watcher) ScanTable(ctx context.Context, job, tableName string, options ListOptions, store CursorStore, processPage func(rows []Row) error) error {
+	cursor, err := store.Load(job)
+	if err != nil {
+		return fmt.Errorf("error loading cursor: %w", err)
+	}
+
+	query := options.query()
+	for {
+		page, err := t.listPage(ctx, tableName, query, cursor)
+		if err != nil && cursor != "" && isExpiredOffsetError(err) {
+			cursor = ""
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := processPage(page.Records); err != nil {
+			return err
+		}
+		if err := store.Save(job, page.Offset); err != nil {
+			return fmt.Errorf("error saving cursor: %w", err)
+		}
+		if page.Offset == "" {
+			return nil
+		}
+		cursor = page.Offset
+	}
+}
+
+// isExpiredOffsetError Check if airtable rejected a list offset because it expired
+func isExpiredOffsetError(err error) bool {
+	apiErr := airtable.Error{}
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity && apiErr.Type == "LIST_RECORDS_ITERATOR_NOT_AVAILABLE"
+}
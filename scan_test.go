@@ -0,0 +1,77 @@
+package airtablewatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGetRowsPage(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.PageSize = 2
+	for i := 0; i < 3; i++ {
+		fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	}
+	watcher := newFakeWatcher(t, fake)
+
+	rows, offset, err := watcher.GetRowsPage("Tasks", ListOptions{}, "")
+	if err != nil || len(rows) != 2 || offset == "" {
+		t.Fatalf("Incorrect first page %v %q %v", rows, offset, err)
+	}
+	rows, offset, err = watcher.GetRowsPage("Tasks", ListOptions{}, offset)
+	if err != nil || len(rows) != 1 || offset != "" {
+		t.Errorf("Incorrect last page %v %q %v", rows, offset, err)
+	}
+}
+
+func TestScanTable(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.PageSize = 2
+	for i := 0; i < 5; i++ {
+		fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	}
+	watcher := newFakeWatcher(t, fake)
+	store := NewMemoryCursorStore()
+
+	// Stop after the second page, as if the process died
+	processed := 0
+	pages := 0
+	errStop := errors.New("stop")
+	err := watcher.ScanTable(context.Background(), "nightly", "Tasks", ListOptions{}, store, func(rows []Row) error {
+		pages++
+		if pages == 2 {
+			return errStop
+		}
+		processed += len(rows)
+		return nil
+	})
+	if err != errStop || processed != 2 {
+		t.Fatalf("Incorrect first run %v %d", err, processed)
+	}
+
+	// Resume from the failed page
+	err = watcher.ScanTable(context.Background(), "nightly", "Tasks", ListOptions{}, store, func(rows []Row) error {
+		processed += len(rows)
+		return nil
+	})
+	if err != nil || processed != 5 {
+		t.Errorf("Expected to resume and process every row once, got %d %v", processed, err)
+	}
+	if cursor, _ := store.Load("nightly"); cursor != "" {
+		t.Errorf("Cursor should be cleared once done, got %q", cursor)
+	}
+
+	// Expired offsets start over
+	store.Save("nightly", "2")
+	fake.FailNext(422, "LIST_RECORDS_ITERATOR_NOT_AVAILABLE")
+	processed = 0
+	err = watcher.ScanTable(context.Background(), "nightly", "Tasks", ListOptions{}, store, func(rows []Row) error {
+		processed += len(rows)
+		return nil
+	})
+	if err != nil || processed != 5 {
+		t.Errorf("Expected to start over after an expired offset, got %d %v", processed, err)
+	}
+}
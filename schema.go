@@ -0,0 +1,212 @@
+package airtablewatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// TableSchema describes a table, as returned by the airtable metadata API
+type TableSchema struct {
+	ID             string        `json:"id"`
+	Name           string        `json:"name"`
+	PrimaryFieldID string        `json:"primaryFieldId"`
+	Fields         []FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes a single field of a table
+type FieldSchema struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Field type, e.g. "singleLineText", "singleSelect", "number"
+	Type string `json:"type"`
+	// Type specific options, e.g. "choices" for select fields
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// SelectOption is one choice of a singleSelect or multipleSelects field
+type SelectOption struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Color name, e.g. "blueLight2". Empty for fields with colors turned off
+	Color string `json:"color,omitempty"`
+}
+
+// computedFieldTypes are field types airtable calculates, which can't be written
+var computedFieldTypes = map[string]bool{
+	"formula":              true,
+	"rollup":               true,
+	"count":                true,
+	"multipleLookupValues": true,
+	"autoNumber":           true,
+	"createdTime":          true,
+	"lastModifiedTime":     true,
+	"createdBy":            true,
+	"lastModifiedBy":       true,
+	"button":               true,
+}
+
+// Computed Check if airtable calculates the field's value, so it can't be written
+func (f *FieldSchema) Computed() bool {
+	return computedFieldTypes[f.Type]
+}
+
+// Choices Get the options of a select field, nil for other field types
+func (f *FieldSchema) Choices() []SelectOption {
+	raw, ok := f.Options["choices"]
+	if !ok {
+		return nil
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	choices := []SelectOption{}
+	if err := json.Unmarshal(encoded, &choices); err != nil {
+		return nil
+	}
+	return choices
+}
+
+// Field Get a field by name or ID, nil if the table has no such field
+func (s *TableSchema) Field(nameOrID string) *FieldSchema {
+	for i, field := range s.Fields {
+		if field.Name == nameOrID || field.ID == nameOrID {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+// GetTableSchema Get the fields of a table by name or ID from the metadata API, with their IDs, types and options.
+// The schema is cached after the first fetch. Needs a token with the schema.bases:read scope
+func (t *Watcher) GetTableSchema(tableName string) (TableSchema, error) {
+	schema, err := t.tableSchema(tableName, false)
+	if err != nil {
+		return TableSchema{}, err
+	}
+	// Copy so callers can't change the cached fields
+	copied := *schema
+	copied.Fields = append([]FieldSchema(nil), schema.Fields...)
+	return copied, nil
+}
+
+// tableSchema Get the schema of a table by name or ID, cached after the first fetch.
+// Set refresh to fetch the schema again even if cached
+func (t *Watcher) tableSchema(tableName string, refresh bool) (*TableSchema, error) {
+	t.Lock()
+	schema, ok := t.schemas[tableName]
+	t.Unlock()
+	if ok && !refresh {
+		return schema, nil
+	}
+
+	rawBody, err := t.request(context.Background(), "GET", "meta/bases/"+t.airtableBase+"/tables", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error getting schema: %w", err)
+	}
+	response := struct {
+		Tables []TableSchema `json:"tables"`
+	}{}
+	if err := json.Unmarshal(rawBody, &response); err != nil {
+		return nil, fmt.Errorf("error decoding schema: %w", err)
+	}
+
+	// Cache every table by both name and ID
+	t.Lock()
+	defer t.Unlock()
+	for i := range response.Tables {
+		table := &response.Tables[i]
+		t.schemas[table.Name] = table
+		t.schemas[table.ID] = table
+	}
+	schema, ok = t.schemas[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table %s not found in base schema", tableName)
+	}
+	return schema, nil
+}
+
+// checkFields Check that every field name exists in the table, refreshing the cached schema once before failing
+func (t *Watcher) checkFields(tableName string, fields map[string]interface{}) error {
+	schema, err := t.tableSchema(tableName, false)
+	if err != nil {
+		return err
+	}
+	for refreshed := false; ; refreshed = true {
+		unknown := ""
+		for name := range fields {
+			if schema.Field(name) == nil {
+				unknown = name
+				break
+			}
+		}
+		if unknown == "" {
+			return nil
+		}
+		if refreshed {
+			return fmt.Errorf("unknown field %q in table %s", unknown, tableName)
+		}
+		// The schema may have changed since it was cached
+		if schema, err = t.tableSchema(tableName, true); err != nil {
+			return err
+		}
+	}
+}
+
+// SelectOptionColor Get the color of an option of a select field, e.g. "blueLight2", from the table's schema.
+// The cached schema is refreshed once if the option is not in it, as options are often added by hand
+func (t *Watcher) SelectOptionColor(tableName, fieldName, optionName string) (string, error) {
+	for refresh := false; ; refresh = true {
+		schema, err := t.tableSchema(tableName, refresh)
+		if err != nil {
+			return "", err
+		}
+		field := schema.Field(fieldName)
+		if field == nil {
+			return "", fmt.Errorf("unknown field %q in table %s", fieldName, tableName)
+		}
+		if field.Type != "singleSelect" && field.Type != "multipleSelects" {
+			return "", fmt.Errorf("field %s is a %s, not a select field", fieldName, field.Type)
+		}
+		for _, choice := range field.Choices() {
+			if choice.Name == optionName {
+				return choice.Color, nil
+			}
+		}
+		if refresh {
+			return "", fmt.Errorf("field %s has no option %q", fieldName, optionName)
+		}
+	}
+}
+
+// PrimaryFieldName Get the name of a table's primary field from the metadata API
+func (t *Watcher) PrimaryFieldName(tableName string) (string, error) {
+	schema, err := t.tableSchema(tableName, false)
+	if err != nil {
+		return "", err
+	}
+	field := schema.Field(schema.PrimaryFieldID)
+	if field == nil {
+		return "", fmt.Errorf("primary field of table %s not found", tableName)
+	}
+	return field.Name, nil
+}
+
+// GetPrimary Get the name and value of a row's primary field, using the table's schema
+func (t *Watcher) GetPrimary(tableName string, row *Row) (string, interface{}, error) {
+	name, err := t.PrimaryFieldName(tableName)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, row.GetField(name), nil
+}
+
+// SetPrimary Set the primary field of a row, whatever it is called in this table
+func (t *Watcher) SetPrimary(tableName, recordID string, value interface{}) error {
+	name, err := t.PrimaryFieldName(tableName)
+	if err != nil {
+		return err
+	}
+	return t.SetRow(tableName, recordID, map[string]interface{}{name: value})
+}
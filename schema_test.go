@@ -0,0 +1,122 @@
+package airtablewatcher
+
+import (
+	"testing"
+)
+
+// tasksSchema is the schema of the Tasks table used in tests
+var tasksSchema = map[string]interface{}{
+	"id":             "tblTASKSTASKSTASK",
+	"name":           "Tasks",
+	"primaryFieldId": "fldNAMENAMENAMENA",
+	"fields": []interface{}{
+		map[string]interface{}{"id": "fldNAMENAMENAMENA", "name": "Name", "type": "singleLineText"},
+		map[string]interface{}{"id": "fldSTATESTATESTAT", "name": "State", "type": "singleSelect", "options": map[string]interface{}{
+			"choices": []interface{}{
+				map[string]interface{}{"id": "selTODO", "name": "ToDo", "color": "blueLight2"},
+				map[string]interface{}{"id": "selDONE", "name": "Done", "color": "greenLight2"},
+			},
+		}},
+	},
+}
+
+func TestStrictFields(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddTableSchema(tasksSchema)
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "New"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.StrictFields = true
+
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"State": "ToDo"}); err != nil {
+		t.Errorf("Known field should be written: %s", err)
+	}
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"fldSTATESTATESTAT": "ToDo"}); err != nil {
+		t.Errorf("Field IDs should be accepted: %s", err)
+	}
+	err := watcher.SetRow("Tasks", id, map[string]interface{}{"Stat": "ToDo"})
+	if err == nil {
+		t.Errorf("Unknown field should error")
+		return
+	}
+	if err.Error() != `unknown field "Stat" in table Tasks` {
+		t.Errorf("Error should name the field: %s", err)
+	}
+	if fake.Record("Tasks", id)["Stat"] != nil {
+		t.Errorf("Unknown field should not have been written")
+	}
+}
+
+func TestGetPrimary(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddTableSchema(tasksSchema)
+	id := fake.AddRecord("Tasks", map[string]interface{}{"Name": "First", "State": "New"})
+	watcher := newFakeWatcher(t, fake)
+
+	row, _ := watcher.GetRow("Tasks", id)
+	name, value, err := watcher.GetPrimary("Tasks", row)
+	if err != nil || name != "Name" || value != "First" {
+		t.Errorf("Incorrect primary field %s=%v: %v", name, value, err)
+	}
+	if name, value := row.PrimaryField(); name != "Name" || value != "First" {
+		t.Errorf("Incorrect guessed primary field %s=%v", name, value)
+	}
+
+	if err := watcher.SetPrimary("Tasks", id, "Renamed"); err != nil {
+		t.Errorf(err.Error())
+	}
+	if fake.Record("Tasks", id)["Name"] != "Renamed" {
+		t.Errorf("Primary field not set")
+	}
+}
+
+func TestGetTableSchema(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddTableSchema(tasksSchema)
+	watcher := newFakeWatcher(t, fake)
+
+	schema, err := watcher.GetTableSchema("Tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.ID != "tblTASKSTASKSTASK" || len(schema.Fields) != 2 {
+		t.Fatalf("Incorrect schema %+v", schema)
+	}
+	state := schema.Field("State")
+	if state == nil || state.Type != "singleSelect" || state.Options["choices"] == nil {
+		t.Errorf("Incorrect field %+v", state)
+	}
+	if byID, err := watcher.GetTableSchema("tblTASKSTASKSTASK"); err != nil || byID.Name != "Tasks" {
+		t.Errorf("Schema not found by ID %v", err)
+	}
+	if _, err := watcher.GetTableSchema("Missing"); err == nil {
+		t.Errorf("Expected error for missing table")
+	}
+}
+
+func TestSelectOptionColor(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddTableSchema(tasksSchema)
+	watcher := newFakeWatcher(t, fake)
+
+	if color, err := watcher.SelectOptionColor("Tasks", "State", "Done"); err != nil || color != "greenLight2" {
+		t.Errorf("Incorrect color %q %v", color, err)
+	}
+	if _, err := watcher.SelectOptionColor("Tasks", "State", "Blocked"); err == nil {
+		t.Errorf("Expected error for missing option")
+	}
+	if _, err := watcher.SelectOptionColor("Tasks", "Name", "Done"); err == nil {
+		t.Errorf("Expected error for a field that is not a select")
+	}
+	if _, err := watcher.SelectOptionColor("Tasks", "Missing", "Done"); err == nil {
+		t.Errorf("Expected error for missing field")
+	}
+
+	schema, _ := watcher.GetTableSchema("Tasks")
+	if choices := schema.Field("State").Choices(); len(choices) != 2 || choices[0] != (SelectOption{ID: "selTODO", Name: "ToDo", Color: "blueLight2"}) {
+		t.Errorf("Incorrect choices %+v", choices)
+	}
+}
@@ -0,0 +1,81 @@
+package airtablewatcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Snapshot is a copy of every row of a table, see SnapshotTable
+type Snapshot struct {
+	TableName string
+	Taken     time.Time
+	Rows      []Row
+}
+
+// SnapshotTable Copy every row of a table, e.g. as a backup before a bulk change
+func (t *Watcher) SnapshotTable(tableName string) (Snapshot, error) {
+	rows, err := t.listRecords(context.Background(), tableName, ListOptions{}.query())
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("error getting rows: %w", err)
+	}
+	return Snapshot{TableName: tableName, Taken: time.Now(), Rows: rows}, nil
+}
+
+// RestoreTable Write a snapshot's fields back to a table. Rows that still exist are updated, including clearing
+// fields that were empty in the snapshot, and rows deleted since are created again with new record IDs.
+// Rows created since the snapshot are left alone. Computed fields are skipped using the table's schema,
+// so an error is returned without writing anything if the schema can't be read
+func (t *Watcher) RestoreTable(tableName string, snapshot Snapshot) error {
+	ctx := context.Background()
+	// Airtable rejects writes to computed fields, which the snapshot has too
+	schema, err := t.tableSchema(tableName, false)
+	if err != nil {
+		return fmt.Errorf("error getting schema to find computed fields, it needs the schema.bases:read scope: %w", err)
+	}
+	current, err := t.listRecords(ctx, tableName, ListOptions{}.query())
+	if err != nil {
+		return fmt.Errorf("error getting rows: %w", err)
+	}
+	existing := rowsByID(current)
+
+	writable := func(name string) bool {
+		field := schema.Field(name)
+		return field == nil || !field.Computed()
+	}
+
+	updates := []recordUpdate{}
+	creates := []map[string]interface{}{}
+	for _, row := range snapshot.Rows {
+		fields := map[string]interface{}{}
+		rowFields, _ := row.Fields.(map[string]interface{})
+		for name, value := range rowFields {
+			if writable(name) {
+				fields[name] = value
+			}
+		}
+
+		currentRow, ok := existing[row.ID]
+		if !ok {
+			creates = append(creates, fields)
+			continue
+		}
+		// Airtable leaves empty fields out, so clear anything filled in since
+		currentFields, _ := currentRow.Fields.(map[string]interface{})
+		for name := range currentFields {
+			if _, ok := fields[name]; !ok && writable(name) {
+				fields[name] = nil
+			}
+		}
+		updates = append(updates, recordUpdate{ID: row.ID, Fields: fields})
+	}
+
+	if _, err := t.updateRecords(ctx, tableName, updates); err != nil {
+		return err
+	}
+	if _, err := t.createRecords(ctx, tableName, creates); err != nil {
+		return err
+	}
+
+	return nil
+}
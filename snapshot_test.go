@@ -0,0 +1,75 @@
+package airtablewatcher
+
+import (
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddTableSchema(map[string]interface{}{
+		"id":   "tblTASKSTASKSTASK",
+		"name": "Tasks",
+		"fields": []interface{}{
+			map[string]interface{}{"id": "fldSTATESTATESTAT", "name": "State", "type": "singleLineText"},
+			map[string]interface{}{"id": "fldNOTESNOTESNOTE", "name": "Notes", "type": "multilineText"},
+			map[string]interface{}{"id": "fldCOUNTCOUNTCOUN", "name": "Count", "type": "formula"},
+		},
+	})
+	changed := fake.AddRecord("Tasks", map[string]interface{}{"State": "Stuck", "Count": 1.0})
+	deleted := fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	watcher := newFakeWatcher(t, fake)
+
+	snapshot, err := watcher.SnapshotTable("Tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Rows) != 2 {
+		t.Fatalf("Expected 2 rows in the snapshot, got %d", len(snapshot.Rows))
+	}
+
+	// Destructive changes
+	watcher.TransitionMatching("Tasks", "State", "Stuck", "ToDo")
+	fake.SetField("Tasks", changed, "Notes", "added later")
+	fake.SetField("Tasks", changed, "Count", 2.0)
+	fake.DeleteRecord("Tasks", deleted)
+
+	if err := watcher.RestoreTable("Tasks", snapshot); err != nil {
+		t.Fatal(err)
+	}
+	record := fake.Record("Tasks", changed)
+	if record["State"] != "Stuck" || record["Notes"] != nil {
+		t.Errorf("Row not restored %v", record)
+	}
+	if record["Count"] != 2.0 {
+		t.Errorf("Computed field was written")
+	}
+	ids := fake.RecordIDs("Tasks")
+	if len(ids) != 2 {
+		t.Fatalf("Deleted row not recreated, rows %v", ids)
+	}
+	if fake.Record("Tasks", ids[1])["State"] != "Done" {
+		t.Errorf("Recreated row has incorrect fields %v", fake.Record("Tasks", ids[1]))
+	}
+}
+
+func TestRestoreTableWithoutSchema(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "Stuck", "Count": 1.0})
+	watcher := newFakeWatcher(t, fake)
+
+	snapshot, err := watcher.SnapshotTable("Tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.SetField("Tasks", id, "State", "ToDo")
+
+	// Without a schema computed fields can't be told apart, so nothing is written
+	if err := watcher.RestoreTable("Tasks", snapshot); err == nil {
+		t.Errorf("Expected error without a schema")
+	}
+	if fake.Record("Tasks", id)["State"] != "ToDo" {
+		t.Errorf("Nothing should be written without a schema")
+	}
+}
@@ -3,6 +3,10 @@ package airtablewatcher
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +16,8 @@ import (
 // Defaults
 const (
 	DefaultAirtablePollInterval = time.Second * 10
+	DefaultEmptyTableCycles     = 3
+	DefaultDrainMaxBurst        = 10
 	DefaultAirtableTable        = "Tasks"
 	DefaultConfigTableName      = "Config"
 )
@@ -19,34 +25,340 @@ const (
 // Watcher configuration to watch airtable for a change in state
 type Watcher struct {
 	PollInterval time.Duration
+	// OnTableEmpty is called when a watched table has had no rows for EmptyTableCycles polls in a row,
+	// which often means a sync broke or the table was cleared. It is called again only after rows reappear
+	OnTableEmpty func(tableName string, cycles int)
+	// EmptyTableCycles sets how many empty polls in a row call OnTableEmpty, by table name.
+	// Tables not listed use DefaultEmptyTableCycles
+	EmptyTableCycles map[string]int
+	// StreamPages checks and dispatches each page of rows as it arrives instead of fetching whole tables first,
+	// bounding memory to a page on very large tables. DispatchOrder then only orders rows within a page
+	StreamPages bool
+	// OnPollLag is called when a poll cycle takes longer than PollInterval, meaning the watcher is falling behind
+	OnPollLag func(duration time.Duration)
+	// ActiveHoursConfigKey is a config table key holding a daily window like "09:00-17:00" outside of which rows are not dispatched.
+	// The window is re-read every poll, a missing or empty value means always active.
+	// An invalid value is logged as an error and nothing is dispatched until it is fixed
+	ActiveHoursConfigKey string
+	// TimezoneConfigKey is a config table key holding the IANA timezone of the active hours, e.g. "America/New_York".
+	// The local timezone is used if it is not set
+	TimezoneConfigKey string
+	// SkipPollsOutsideActiveHours stops fetching rows at all outside the active hours, instead of only holding back dispatch
+	SkipPollsOutsideActiveHours bool
+	// CreateDefaults holds fields by table name to set on every row created in that table,
+	// unless the row sets them itself. Used by BatchCreateRows and BestEffortTransaction
+	CreateDefaults map[string]map[string]interface{}
+	// CoalesceWrites queues the watcher's own bookkeeping writes, such as clearing ProcessingField, with QueueSetRow
+	// so they are sent in batches at the end of each poll cycle
+	CoalesceWrites bool
+	// SerializeRowWrites makes SetRow calls to the same record wait for each other, so overlapping writes from
+	// different actions don't race. Writes to different records still run in parallel
+	SerializeRowWrites bool
+	// SkipEmptyWrites drops "" and nil values from SetRow's fields so empty data never clears existing values
+	SkipEmptyWrites bool
+	// AlignPolls schedules polls on wall-clock multiples of PollInterval (e.g. the top of every minute for time.Minute)
+	// instead of PollInterval after the previous poll finished. The first poll still happens as soon as Start is called
+	AlignPolls bool
+	// DrainMode polls again straight away after a cycle that fired at least one action, for queue-style tables,
+	// going back to PollInterval once a cycle fires nothing or DrainMaxBurst polls have run back to back
+	DrainMode bool
+	// DrainMaxBurst caps how many polls DrainMode runs back to back, 0 uses DefaultDrainMaxBurst
+	DrainMaxBurst int
+	// AllowedTables, if set, are the only tables functions can be registered on
+	AllowedTables []string
+	// DeniedTables can never have functions registered on them, even if they are in AllowedTables
+	DeniedTables []string
 	// Table for configuration items with Key,Value fields
 	ConfigTableName string
-	AirtableClient  *airtable.Client
+	// ConfigViewName, if set, limits config reads to the rows in this view of ConfigTableName
+	ConfigViewName string
+	// ConfigCacheTTL keeps config table reads for this long instead of reading the table on every lookup.
+	// 0 disables the cache
+	ConfigCacheTTL time.Duration
+	// ConfigVersionKey, if set, is a config key checked every poll. When its value changes the cached config is
+	// dropped, so a change made by one watcher reaches the others before the TTL. See BumpConfigVersion
+	ConfigVersionKey string
+	AirtableClient   *airtable.Client
+	// StrictFields makes SetRow check field names against the table schema before writing,
+	// returning an error naming any unknown field instead of sending the write
+	StrictFields bool
+	// NumberFormat controls how rows fetched by the watcher convert between numbers and strings
+	NumberFormat NumberFormat
+	// Retry controls how SetRow recovers from transient errors and invalid select options
+	Retry RetryConfig
+	// RequestsPerSecond caps how fast requests are sent to airtable, airtable allows 5 per second per base.
+	// 0 means no cap
+	RequestsPerSecond float64
+	// RequestsPerMinute caps how many requests are sent in any rolling minute, to bound API usage for cost.
+	// Once it is used up polls are skipped and other requests wait for the window to free up. 0 means no cap
+	RequestsPerMinute int
+	// OnBudgetExhausted is called when RequestsPerMinute is used up, with how long until the next request can
+	// be sent. It is called again only after the budget has freed up
+	OnBudgetExhausted func(wait time.Duration)
+	// OnRateLimit is called whenever a request to airtable is rate limited, before waiting to retry.
+	// retryAfter is the Retry-After duration airtable sent, 0 if none
+	OnRateLimit func(tableName string, retryAfter time.Duration)
+	// DispatchOrder sorts rows before they are checked for triggers so processing order is deterministic across restarts.
+	// nil keeps airtable's order. See OrderByRecordID, OrderByCreatedTime and OrderByNumber
+	DispatchOrder RowOrder
+	// ProcessingField, if set, is written with WorkerID while an action runs on a row and cleared when it returns,
+	// even if it is canceled or panics
+	ProcessingField string
+	// WorkerID identifies this watcher, defaults to hostname-pid
+	WorkerID string
+	// DispatchCooldown keeps a row from triggering the same watcher again for this long after its action returns,
+	// covering state writes that haven't shown up in the next poll yet. 0 disables the cooldown
+	DispatchCooldown time.Duration
+	// Breaker stops requests to airtable during an outage, nil disables it.
+	// With a breaker set, Start keeps polling through airtable errors instead of returning them
+	Breaker *CircuitBreaker
+	// OnCircuitOpen is called when the breaker opens, with the failure that opened it
+	OnCircuitOpen func(err error)
+	// ResultFields are the fields WriteResult and ReportResult write action results to, defaults to DefaultResultFields
+	ResultFields ResultFields
+	// OnCancel is called when a running action is canceled because its row changed, with the value of the
+	// watched field at the time and how long the action had been running. Also called when it exceeds its max runtime
+	// field or MaxActionDuration, with the value it was dispatched on for MaxActionDuration
+	OnCancel func(tableName, recordID, value string, elapsed time.Duration)
+	// EventLogSize caps how many events RecentEvents keeps, 0 disables the in-memory log
+	EventLogSize int
+	// OnEvent is called with every event, e.g. to send them to external logging
+	OnEvent func(event Event)
+	// IncludeCommentCount makes GetRows fetch the number of comments on each row, see Row.CommentCount
+	IncludeCommentCount bool
+	// Transport sends requests to airtable, defaults to http.DefaultTransport
+	Transport http.RoundTripper
+	// TableConcurrency limits how many action functions run at once per table name, so one busy table can't starve the others
+	TableConcurrency map[string]int
+	// Leader, if set, makes this one of several watchers where only the current leader polls, see ConfigLeaderLock.
+	// Leadership is renewed before every poll, a watcher that loses it stops dispatching but lets running actions finish
+	Leader LeaderElector
+	// MaxActionDuration cancels any action still running after this long, as a last resort against stuck actions.
+	// An EventTimedOut is logged when it fires. 0 means no limit
+	MaxActionDuration time.Duration
+	// DisableCancelWatch stops re-reading rows while their actions run, so actions are never canceled by row changes
+	// or WithMaxRuntimeField. Watches without cancel values skip it regardless
+	DisableCancelWatch bool
+	// CancelWatchConcurrency limits how many running actions have their rows re-read for cancellation at once,
+	// so a burst of actions doesn't become a burst of requests. Checks wait their turn, 0 means no limit
+	CancelWatchConcurrency int
+	// Concurrency limits how many action functions run at once, adapting to airtable rate limits.
+	// nil means no limit
+	Concurrency *AdaptiveConcurrency
 
 	airtableKey  string
 	airtableBase string
 	timeout      time.Duration
-	watchers     []watch
-	ctx          context.Context
+	// watchersLock guards watchers, so functions can be registered while Start is running
+	watchersLock sync.RWMutex
+	watchers     []*watch
 
-	// Map of rows we ignore since a job is already running for that row
+	requestLimiter requestLimiter
+	requestBudget  requestBudget
+	// running tracks dispatched action functions
+	running sync.WaitGroup
+	// Number of action functions in flight, and how many rows the last poll dispatched
+	inFlight           int
+	lastPollDispatched int
+	polls              int
+	lastPollStarted    time.Time
+	lastPollDuration   time.Duration
+	// Closed and replaced whenever the above change
+	stateChanged chan struct{}
+	// When each (row, watcher, trigger value) last finished, for DispatchCooldown
+	recentlyDispatched map[string]time.Time
+	// Earliest time each (row, watcher) may be dispatched again, for WithMinInterval
+	nextRunAllowed map[string]time.Time
+	// Event log, oldest first
+	events []Event
+	// Set by DrainAndStop to stop dispatching, stopped is closed to stop Start
+	draining bool
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// Cancels the context of actions dispatched by Start, for DrainAndStop giving up on them
+	cancelActions context.CancelFunc
+	// Writes queued by QueueSetRow, by table then record ID
+	pendingWrites map[string]map[string]map[string]interface{}
+	// Consecutive empty polls by table name, for OnTableEmpty
+	emptyCycles map[string]int
+	// Per record locks for SerializeRowWrites
+	rowLocks map[string]*rowLock
+	// Wraps every action, see Use
+	middleware []Middleware
+	// Semaphores for TableConcurrency by table name
+	tableSemaphores map[string]semaphore
+	// Set while Leader reports this watcher as leader
+	leading bool
+	// Config table rows for ConfigCacheTTL, nil when not cached
+	configCache    []Row
+	configCachedAt time.Time
+	// Last value seen in the ConfigVersionKey row
+	configVersion string
+	// Events logged by type, and the total run time of finished and canceled actions, for MetricsHandler
+	eventCounts    map[string]int
+	actionDuration time.Duration
+	// Semaphore for CancelWatchConcurrency, created on first use
+	cancelWatchSemaphore semaphore
+	// GetRows results for the current poll cycle by table then query, nil when not running
+	rowCache map[string]map[string][]Row
+
+	// Map of rows we ignore since a job is already running for that row, by "table/recordID"
 	IgnoreRows map[string]struct{}
+	// Fingerprints of row fields by table then record ID, used by GetRowsChanged
+	rowFingerprints map[string]map[string]string
+	// Rows as of the previous poll by table then record ID, only kept for tables with a watcher that needs them
+	previousRows map[string]map[string]Row
+	// Table schemas by name and ID
+	schemas map[string]*TableSchema
+	// Cached permission level on the base
+	permissionLevel string
 	sync.Mutex
 }
 
 // watch is a an event we are watching for including a specific trigger and action function
 type watch struct {
+	// unique per watcher, in registration order
+	id             int
 	tableName      string
 	fieldName      string
 	triggerValues  []string
 	cancelValues   []string
 	actionFunction ActionFunction
+	// Config key that turns this watcher off when set to "false"
+	enabledConfigKey string
+
+	// trigger overrides matching triggerValues, previous is the row as of the last poll (nil if unseen)
+	trigger func(row, previous *Row) bool
+	// cancel overrides matching cancelValues
+	cancel func(row *Row) bool
+	// usesPrevious is set when trigger needs the previous poll's rows
+	usesPrevious bool
+
+	// Rows must not have been edited for minAge, according to lastModifiedField
+	minAge            time.Duration
+	lastModifiedField string
+
+	// Called once when the watcher stops
+	onShutdown   ShutdownFunction
+	shutdownDone bool
+
+	// Limits how many of this watch's actions run at once, nil for no limit
+	semaphore semaphore
+
+	// Rows never trigger once completionField is completionValue
+	completionField string
+	completionValue string
+
+	// Field holding how long the action may run before it is canceled
+	maxRuntimeField string
+
+	// Re-read the row after the action to check it moved off the trigger value
+	verifyTransition bool
+	// Field to write a warning to when verification fails, "" to only log it
+	verifyWarningField string
+
+	// Applied to the field value and the trigger and cancel values before comparing them, nil to compare as is
+	normalizer func(string) string
+	// Builds the value to match from several fields instead of reading fieldName, see RegisterCompound
+	combine func(row *Row) string
+	// Runs on every watched table, see RegisterGlobal
+	global bool
+	// Decides per row whether to dispatch, nil to always dispatch
+	guard GuardFunction
+	// Least time between dispatches of the same row, 0 for no limit
+	minInterval time.Duration
+}
+
+// triggered Check if a row should trigger this watcher
+func (w *watch) triggered(row, previous *Row) bool {
+	if w.completionField != "" && row.GetFieldString(w.completionField) == w.completionValue {
+		return false
+	}
+	if w.trigger != nil {
+		return w.trigger(row, previous)
+	}
+	return w.matches(w.triggerValues, row)
+}
+
+// settled Check if a row has gone unedited for at least minAge
+func (w *watch) settled(row *Row, now time.Time) bool {
+	if w.minAge <= 0 {
+		return true
+	}
+	lastModified := DefaultBlankTime
+	if w.lastModifiedField != "" {
+		lastModified = row.GetFieldTime(w.lastModifiedField)
+	}
+	if lastModified.Equal(DefaultBlankTime) {
+		lastModified = row.LastModified()
+	}
+	if lastModified.Equal(DefaultBlankTime) {
+		lastModified = row.CreatedTime
+	}
+	// Nothing to go on
+	if lastModified.IsZero() || lastModified.Equal(DefaultBlankTime) {
+		return true
+	}
+	return now.Sub(lastModified) >= w.minAge
+}
+
+// canceled Check if a running action on this row should be canceled
+func (w *watch) canceled(row *Row) bool {
+	if w.cancel != nil {
+		return w.cancel(row)
+	}
+	return w.matches(w.cancelValues, row)
+}
+
+// cancelable Check if anything can cancel this watch's actions, so their rows need watching while they run
+func (w *watch) cancelable() bool {
+	return len(w.cancelValues) > 0 || w.cancel != nil || w.maxRuntimeField != ""
+}
+
+// value Get the value of the watched field, or the combined fields of RegisterCompound
+func (w *watch) value(row *Row) string {
+	if w.combine != nil {
+		return w.combine(row)
+	}
+	return row.GetFieldString(w.fieldName)
+}
+
+// matches Check if the row's field is one of values, after normalizing both
+func (w *watch) matches(values []string, row *Row) bool {
+	value := w.value(row)
+	if w.normalizer == nil {
+		return containsString(values, value)
+	}
+	value = w.normalizer(value)
+	for _, v := range values {
+		if w.normalizer(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsString Check if value is in values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // ActionFunction Function that runs when triggered
 // If the row is changed off the trigger value while the function is still running, the context is canceled
 type ActionFunction func(ctx context.Context, watcher *Watcher, tableName string, airtableRow *Row)
 
+// GuardFunction Function deciding whether a triggered row should be dispatched this cycle, see WithGuard
+type GuardFunction func(ctx context.Context, watcher *Watcher, airtableRow *Row) (bool, error)
+
+// ShutdownFunction Function that runs once when Start's context is canceled, after all running actions have returned.
+// Use it to flush buffers or release external locks
+type ShutdownFunction func(watcher *Watcher, tableName string)
+
 // NewWatcher Create new tasker to watch airtable
 func NewWatcher(airtableKey, airtableBase string) (*Watcher, error) {
 	watcher := &Watcher{
@@ -54,7 +366,22 @@ func NewWatcher(airtableKey, airtableBase string) (*Watcher, error) {
 		airtableBase:    airtableBase,
 		PollInterval:    DefaultAirtablePollInterval,
 		ConfigTableName: DefaultConfigTableName,
+		WorkerID:        defaultWorkerID(),
+		EventLogSize:    DefaultEventLogSize,
+		ResultFields:    DefaultResultFields,
 		IgnoreRows:      map[string]struct{}{},
+		rowFingerprints: map[string]map[string]string{},
+		previousRows:    map[string]map[string]Row{},
+		schemas:         map[string]*TableSchema{},
+		stateChanged:    make(chan struct{}),
+		tableSemaphores: map[string]semaphore{},
+		stopped:         make(chan struct{}),
+		rowLocks:        map[string]*rowLock{},
+		pendingWrites:   map[string]map[string]map[string]interface{}{},
+		emptyCycles:     map[string]int{},
+
+		recentlyDispatched: map[string]time.Time{},
+		nextRunAllowed:     map[string]time.Time{},
 	}
 	err := watcher.connect()
 	if err != nil {
@@ -70,6 +397,7 @@ func (t *Watcher) connect() error {
 	if err != nil {
 		return err
 	}
+	airtableClient.HTTPClient = &http.Client{Transport: &watcherTransport{watcher: t}}
 	t.AirtableClient = airtableClient
 
 	return nil
@@ -77,104 +405,786 @@ func (t *Watcher) connect() error {
 
 // RegisterFunction Register a function to run on an airtable row when the state is changed to the trigger state.
 // cancelValue will cancel the function when any of the cancelValues is matched
-func (t *Watcher) RegisterFunction(tableName, fieldName string, triggerValues []string, actionFunction ActionFunction, cancelValue ...string) {
-	t.watchers = append(t.watchers, watch{tableName, fieldName, triggerValues, cancelValue, actionFunction})
+func (t *Watcher) RegisterFunction(tableName, fieldName string, triggerValues []string, actionFunction ActionFunction, cancelValue ...string) error {
+	return t.RegisterFunctionWithOptions(tableName, fieldName, triggerValues, actionFunction, WithCancelValues(cancelValue...))
+}
+
+// RegisterFunctionWithOptions Register a function the same way as RegisterFunction, configured with options
+func (t *Watcher) RegisterFunctionWithOptions(tableName, fieldName string, triggerValues []string, actionFunction ActionFunction, options ...WatchOption) error {
+	return t.addWatch(watch{
+		tableName:      tableName,
+		fieldName:      fieldName,
+		triggerValues:  triggerValues,
+		actionFunction: actionFunction,
+	}, options...)
+}
+
+// Middleware wraps an action function with extra behavior, calling next to run the action
+type Middleware func(next ActionFunction) ActionFunction
+
+// Use Wrap every registered action with middleware, e.g. for logging or panic recovery.
+// Middleware applies at dispatch time, so it also covers functions registered earlier.
+// The first middleware added is outermost and runs first
+func (t *Watcher) Use(middleware Middleware) {
+	t.Lock()
+	defer t.Unlock()
+	t.middleware = append(t.middleware, middleware)
+}
+
+// withMiddleware Wrap an action in every middleware
+func (t *Watcher) withMiddleware(action ActionFunction) ActionFunction {
+	t.Lock()
+	defer t.Unlock()
+	for i := len(t.middleware) - 1; i >= 0; i-- {
+		action = t.middleware[i](action)
+	}
+	return action
+}
+
+// addWatch applies the options to a watch and adds it to the list of watchers,
+// unless the table policy forbids its table
+func (t *Watcher) addWatch(watcher watch, options ...WatchOption) error {
+	if err := t.checkTable(watcher.tableName); err != nil {
+		return err
+	}
+	for _, option := range options {
+		option(&watcher)
+	}
+	t.watchersLock.Lock()
+	defer t.watchersLock.Unlock()
+	watcher.id = len(t.watchers)
+	t.watchers = append(t.watchers, &watcher)
+	return nil
+}
+
+// snapshotWatchers Get the registered watchers as of now, safe to range over while more are registered
+func (t *Watcher) snapshotWatchers() []*watch {
+	t.watchersLock.RLock()
+	defer t.watchersLock.RUnlock()
+	return append([]*watch(nil), t.watchers...)
+}
+
+// ErrTableNotAllowed is returned when registering a function on a table AllowedTables or DeniedTables forbids
+var ErrTableNotAllowed = errors.New("table not allowed")
+
+// checkTable Check a table against AllowedTables and DeniedTables
+func (t *Watcher) checkTable(tableName string) error {
+	// Global watchers only see tables other watchers were allowed on
+	if tableName == AllTables {
+		return nil
+	}
+	if containsString(t.DeniedTables, tableName) || (len(t.AllowedTables) > 0 && !containsString(t.AllowedTables, tableName)) {
+		return fmt.Errorf("%w: %s", ErrTableNotAllowed, tableName)
+	}
+	return nil
 }
 
 // Start watch airtable for triggers, blocking function.
-// The context applies to all sub tasks, if the context is canceled, all registered functions will be cancelled
-// TODO: Make threadsafe
+// The context applies to all sub tasks, if the context is canceled, all registered functions will be cancelled.
+// Functions can still be registered while it runs, they are picked up from the next poll
 func (t *Watcher) Start(ctx context.Context) error {
-	t.ctx = ctx
+	actionCtx, cancelActions := t.actionContext(ctx)
+	defer cancelActions()
+
+	burst := 0
 	for {
-		// Get all tables we need to scan
-		tables := map[string]bool{}
-		for _, watcher := range t.watchers {
-			tables[watcher.tableName] = true
+		fired, err := t.pollIfLeading(actionCtx)
+		if err != nil {
+			return err
 		}
 
-		// Go through each row in each table
-		for tableName := range tables {
-			rows, err := t.GetRows(tableName)
+		// Wait for the next poll, or stop
+		delay := t.nextPollDelay(time.Now())
+		if t.drainBurst(fired, &burst) {
+			delay = 0
+		}
+		select {
+		case <-ctx.Done():
+			t.shutdown()
+			return ctx.Err()
+		case <-t.stopped:
+			t.shutdown()
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// actionContext Get the context Start dispatches actions with, which DrainAndStop cancels if they don't finish in time
+func (t *Watcher) actionContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	t.Lock()
+	t.cancelActions = cancel
+	t.Unlock()
+	return ctx, cancel
+}
+
+// pollIfLeading Run a poll cycle unless another watcher is the Leader, returning whether it fired any action.
+// Poll errors are logged, and only returned when the watcher should stop on them because there is no Breaker
+func (t *Watcher) pollIfLeading(ctx context.Context) (bool, error) {
+	// Standby watchers only check for leadership
+	if t.Leader != nil && !t.lead(ctx) {
+		return false, nil
+	}
+	result, err := t.pollCycle(ctx)
+	if err != nil {
+		t.logEvent(Event{Type: EventError, Detail: err.Error()})
+		if t.Breaker == nil {
+			t.stepDown()
+			return false, err
+		}
+	}
+	return result.Total().Fired > 0, nil
+}
+
+// recordPollDuration Remember how long a poll cycle took, calling OnPollLag if it took longer than PollInterval
+func (t *Watcher) recordPollDuration(started time.Time, duration time.Duration) {
+	t.Lock()
+	t.lastPollStarted = started
+	t.lastPollDuration = duration
+	onPollLag := t.OnPollLag
+	t.Unlock()
+
+	if onPollLag != nil && t.PollInterval > 0 && duration > t.PollInterval {
+		onPollLag(duration)
+	}
+}
+
+// nextPollDelay Get how long to wait after a poll finishing at now before the next one
+func (t *Watcher) nextPollDelay(now time.Time) time.Duration {
+	if !t.AlignPolls || t.PollInterval <= 0 {
+		return t.PollInterval
+	}
+	// Truncate rounds relative to the zero time, which lines up with UTC minutes, hours and days
+	return now.Truncate(t.PollInterval).Add(t.PollInterval).Sub(now)
+}
+
+// drainBurst Check if DrainMode should poll again straight away, counting polls in the current burst
+func (t *Watcher) drainBurst(fired bool, burst *int) bool {
+	maxBurst := t.DrainMaxBurst
+	if maxBurst <= 0 {
+		maxBurst = DefaultDrainMaxBurst
+	}
+	if !t.DrainMode || !fired || *burst >= maxBurst {
+		*burst = 0
+		return false
+	}
+	*burst++
+	return true
+}
+
+// DrainAndStop Stop dispatching new actions, wait for running actions to finish, then stop Start (which returns nil).
+// If actions are still running when the context is done their contexts are canceled and the context's error is
+// returned, Start is stopped either way once they return.
+// The watcher can't be started again afterwards
+func (t *Watcher) DrainAndStop(ctx context.Context) error {
+	t.Lock()
+	t.draining = true
+	t.notifyStateChanged()
+	t.Unlock()
+	defer t.stopOnce.Do(func() { close(t.stopped) })
+
+	for {
+		t.Lock()
+		drained := t.inFlight == 0
+		changed := t.stateChanged
+		t.Unlock()
+		if drained {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Lock()
+			cancelActions := t.cancelActions
+			t.Unlock()
+			if cancelActions != nil {
+				cancelActions()
+			}
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// shutdown waits for running action functions to return then calls each watcher's shutdown function once
+func (t *Watcher) shutdown() {
+	t.running.Wait()
+	t.flushAndLog()
+	t.stepDown()
+	for _, watcher := range t.snapshotWatchers() {
+		if watcher.onShutdown == nil || watcher.shutdownDone {
+			continue
+		}
+		watcher.shutdownDone = true
+		watcher.onShutdown(t, watcher.tableName)
+	}
+}
+
+// RunOnce run a single poll cycle and wait for every action function it (or an earlier cycle) started to return.
+// Useful for driving the watcher synchronously in tests
+func (t *Watcher) RunOnce(ctx context.Context) error {
+	_, err := t.RunOnceResult(ctx)
+	return err
+}
+
+// RunOnceResult run a single poll cycle like RunOnce, returning what it did with each table's rows.
+// Lets tests assert on how many rows matched or fired instead of waiting on timing
+func (t *Watcher) RunOnceResult(ctx context.Context) (PollResult, error) {
+	result, err := t.pollCycle(ctx)
+	t.running.Wait()
+	t.flushAndLog()
+	return result, err
+}
+
+// WaitIdle Block until no action functions are running and the last poll found nothing new to run,
+// or the context is canceled.
+// Returns immediately if that is already the case, and waits for a first poll if there hasn't been one
+func (t *Watcher) WaitIdle(ctx context.Context) error {
+	for {
+		t.Lock()
+		idle := t.polls > 0 && t.inFlight == 0 && t.lastPollDispatched == 0
+		changed := t.stateChanged
+		t.Unlock()
+		if idle {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// notifyStateChanged wakes up everything waiting on a change to the watcher's state, must hold the lock
+func (t *Watcher) notifyStateChanged() {
+	close(t.stateChanged)
+	t.stateChanged = make(chan struct{})
+}
+
+// pollCycle Check every watched table once, dispatching triggered rows
+func (t *Watcher) pollCycle(ctx context.Context) (PollResult, error) {
+	result := PollResult{Tables: map[string]TableResult{}}
+	t.Lock()
+	draining := t.draining
+	t.Unlock()
+	if draining {
+		return result, nil
+	}
+
+	// Leave the remaining requests to running actions until the window frees up
+	if t.budgetExhausted() {
+		return result, nil
+	}
+
+	started := time.Now()
+	defer func() { t.recordPollDuration(started, time.Since(started)) }()
+	// Send writes queued during the cycle before sleeping
+	defer t.flushAndLog()
+
+	// Start a fresh cycle cache, dropped when the cycle ends so nothing reads its rows while the watcher sleeps
+	t.setRowCaching(true)
+	defer t.setRowCaching(false)
+	if err := t.checkConfigVersion(ctx); err != nil {
+		t.logEvent(Event{Type: EventError, Detail: err.Error()})
+	}
+
+	// Check the config table's schedule
+	active, err := t.active(time.Now())
+	if err != nil {
+		t.logEvent(Event{Type: EventError, Detail: fmt.Sprintf("error checking active hours, not dispatching: %s", err)})
+	}
+	if !active && t.SkipPollsOutsideActiveHours {
+		return result, nil
+	}
+
+	// Get all tables we need to scan, and whether we need to remember their rows
+	watchers := t.snapshotWatchers()
+	tables := map[string]bool{}
+	for _, watcher := range watchers {
+		if watcher.global {
+			continue
+		}
+		tables[watcher.tableName] = tables[watcher.tableName] || watcher.usesPrevious
+	}
+
+	// Leave out watchers turned off in the config table
+	watchers, err = t.enabledWatchers(watchers)
+	if err != nil {
+		return result, err
+	}
+
+	if t.StreamPages {
+		// Check each page of each table as it arrives
+		for tableName, usesPrevious := range tables {
+			tableResult, err := t.streamTable(ctx, tableName, usesPrevious, watchers, active)
+			result.Tables[tableName] = tableResult
 			if err != nil {
-				return err
+				return result, err
+			}
+		}
+	} else {
+		// Fetch every table at once
+		tableNames := make([]string, 0, len(tables))
+		for tableName := range tables {
+			tableNames = append(tableNames, tableName)
+		}
+		allRows, errs := t.getRowsMulti(tableNames)
+		if err := firstTableError(tableNames, errs); err != nil {
+			for tableName := range errs {
+				result.Tables[tableName] = TableResult{Errored: 1}
+			}
+			return result, err
+		}
+
+		// Go through each row in each table
+		for tableName, usesPrevious := range tables {
+			rows := allRows[tableName]
+			sortRows(rows, t.DispatchOrder)
+
+			t.Lock()
+			previousRows := t.previousRows[tableName]
+			t.Unlock()
+			var nextRows map[string]Row
+			if usesPrevious {
+				nextRows = rowsByID(rows)
 			}
 
-			// Check each row
-		rowLoop:
-			for _, row := range rows {
-				// Check if this row should be ignored
+			t.trackEmpty(tableName, len(rows))
+			tableResult := TableResult{}
+			t.checkRows(ctx, tableName, rows, previousRows, nextRows, watchers, active, &tableResult)
+			result.Tables[tableName] = tableResult
+			if usesPrevious {
 				t.Lock()
-				if _, ok := t.IgnoreRows[row.ID]; ok {
-					t.Unlock()
-					continue
-				}
+				t.previousRows[tableName] = nextRows
 				t.Unlock()
+			}
+		}
+	}
 
-				// Check each watcher
-				for _, watcher := range t.watchers {
-					// Check tableName
-					if watcher.tableName != tableName {
-						continue
-					}
-
-					for _, triggerValue := range watcher.triggerValues {
-						if row.GetFieldString(watcher.fieldName) == triggerValue {
-							// Add to list of rows we are ignoring
-							t.Lock()
-							t.IgnoreRows[row.ID] = struct{}{}
-							t.Unlock()
-
-							// We should run this action function!
-							// Run it in a new thread
-							go func(row Row) {
-								actionFunctionCtx, actionFunctionCancel := context.WithCancel(t.ctx)
-
-								// Cancel context if fieldName =/= triggerValue
-								go t.watchForCancel(actionFunctionCtx, &row, &watcher, actionFunctionCancel)
-
-								// Call action
-								watcher.actionFunction(actionFunctionCtx, t, tableName, &row)
-
-								actionFunctionCancel()
-
-								// Remove from rows we ignore
-								t.Lock()
-								delete(t.IgnoreRows, row.ID)
-								t.Unlock()
-							}(row)
-
-							// No need to check this row anymore
-							continue rowLoop
-						}
-					}
+	t.Lock()
+	t.pruneCooldowns()
+	t.polls++
+	t.lastPollDispatched = result.Total().Fired
+	t.notifyStateChanged()
+	t.Unlock()
+
+	return result, nil
+}
+
+// checkRows Check rows of a table against watchers and dispatch the ones that trigger, counting what happened in result.
+// nextRows, if not nil, is the table's previous rows for the next poll. Rows a previous-row trigger matched but that
+// were held back keep their old previous row there, so the change still triggers once they can be dispatched
+func (t *Watcher) checkRows(ctx context.Context, tableName string, rows []Row, previousRows, nextRows map[string]Row, watchers []*watch, active bool, result *TableResult) {
+	// Check each row
+rowLoop:
+	for _, row := range rows {
+		result.Scanned++
+		// Check if this row should be ignored
+		t.Lock()
+		_, ignored := t.IgnoreRows[tableName+"/"+row.ID]
+		t.Unlock()
+
+		t.checkGlobal(ctx, tableName, row, watchers, active, result)
+
+		// Check each watcher
+		for _, watcher := range watchers {
+			// Check tableName
+			if watcher.tableName != tableName {
+				continue
+			}
+
+			var previous *Row
+			if previousRow, ok := previousRows[row.ID]; ok {
+				previous = &previousRow
+			}
+			if watcher.triggered(&row, previous) {
+				dispatchCtx := ctx
+				if watcher.usesPrevious {
+					dispatchCtx = context.WithValue(ctx, previousRowKey{}, previous)
 				}
+				dispatched := t.tryDispatch(dispatchCtx, tableName, row, watcher, ignored, active, result)
+				if !dispatched && watcher.usesPrevious && previous != nil && nextRows != nil {
+					nextRows[row.ID] = *previous
+				}
+				// No need to check this row anymore
+				continue rowLoop
 			}
 		}
+	}
+}
 
-		// Check context
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+// previousRowKey is the context key of the previous poll's row a dispatch triggered on, for previous-row triggers
+type previousRowKey struct{}
+
+// previousRow Get the previous poll's row an action context was dispatched with, nil if there is none
+func previousRow(ctx context.Context) *Row {
+	row, _ := ctx.Value(previousRowKey{}).(*Row)
+	return row
+}
+
+// checkGlobal Check a row against the RegisterGlobal watchers, which run alongside the table's own watchers
+func (t *Watcher) checkGlobal(ctx context.Context, tableName string, row Row, watchers []*watch, active bool, result *TableResult) {
+	for _, global := range watchers {
+		if !global.global {
+			continue
+		}
+		// A copy for this table, so dispatch and the action see the real table name
+		watcher := *global
+		watcher.tableName = tableName
+		if !watcher.triggered(&row, nil) {
+			continue
 		}
+		t.Lock()
+		_, ignored := t.IgnoreRows[dispatchKey(&row, &watcher)]
+		t.Unlock()
+		t.tryDispatch(ctx, tableName, row, &watcher, ignored, active, result)
+	}
+}
+
+// tryDispatch Dispatch a triggered row unless it should be held back, counting what happened in result.
+// Returns whether it was dispatched
+func (t *Watcher) tryDispatch(ctx context.Context, tableName string, row Row, watcher *watch, ignored, active bool, result *TableResult) bool {
+	result.Matched++
+	t.logEvent(Event{Type: EventMatched, TableName: tableName, RecordID: row.ID})
+	if !watcher.settled(&row, time.Now()) {
+		result.Skipped++
+		t.logEvent(Event{Type: EventSkipped, TableName: tableName, RecordID: row.ID, Detail: "not settled"})
+		return false
+	}
+	if ignored {
+		result.Deduped++
+		t.logEvent(Event{Type: EventDeduped, TableName: tableName, RecordID: row.ID, Detail: "already running"})
+		return false
+	}
+	if t.coolingDown(&row, watcher) {
+		result.Deduped++
+		t.logEvent(Event{Type: EventDeduped, TableName: tableName, RecordID: row.ID, Detail: "cooling down"})
+		return false
+	}
+	if t.withinMinInterval(&row, watcher) {
+		result.Deduped++
+		t.logEvent(Event{Type: EventDeduped, TableName: tableName, RecordID: row.ID, Detail: "within min interval"})
+		return false
+	}
+	if !active {
+		result.Skipped++
+		t.logEvent(Event{Type: EventSkipped, TableName: tableName, RecordID: row.ID, Detail: "outside active hours"})
+		return false
+	}
+	if watcher.guard != nil {
+		allowed, err := watcher.guard(ctx, t, &row)
+		if err != nil {
+			t.logEvent(Event{Type: EventError, TableName: tableName, RecordID: row.ID, Detail: fmt.Sprintf("error checking guard: %s", err)})
+		}
+		if err != nil || !allowed {
+			result.Skipped++
+			t.logEvent(Event{Type: EventSkipped, TableName: tableName, RecordID: row.ID, Detail: "guard"})
+			return false
+		}
+	}
+	if !t.dispatch(ctx, row, watcher) {
+		return false
+	}
+	result.Fired++
+	return true
+}
 
-		time.Sleep(t.PollInterval)
+// dispatchKey Get the key a dispatched row is kept under in IgnoreRows while its action runs, "table/recordID".
+// Global watchers use their own key, so they don't hold back the table's watchers or each other
+func dispatchKey(row *Row, watcher *watch) string {
+	if watcher.global {
+		return fmt.Sprintf("%s/%s/%d", watcher.tableName, row.ID, watcher.id)
 	}
+	return watcher.tableName + "/" + row.ID
 }
 
-// watchForCancel watches a row if it changes to a cancel value, if it does, cancels the context
-func (t *Watcher) watchForCancel(ctx context.Context, row *Row, watcher *watch, actionFunctionCancel context.CancelFunc) {
+// streamTable Check a table page by page as the pages arrive, so only one page of rows is held at a time.
+// Rows are only sorted within each page. Tables that use the previous poll's rows still remember every row
+func (t *Watcher) streamTable(ctx context.Context, tableName string, usesPrevious bool, watchers []*watch, active bool) (TableResult, error) {
+	t.Lock()
+	previousRows := t.previousRows[tableName]
+	t.Unlock()
+
+	query := ListOptions{CommentCount: t.IncludeCommentCount}.query()
+	seen := map[string]Row{}
+	result := TableResult{}
+	offset := ""
 	for {
-		rowUpdated, err := t.GetRow(watcher.tableName, row.ID)
+		page, err := t.listPage(ctx, tableName, query, offset)
 		if err != nil {
+			result.Errored++
+			return result, fmt.Errorf("error getting rows of %s: %w", tableName, err)
+		}
+		sortRows(page.Records, t.DispatchOrder)
+		if usesPrevious {
+			for _, row := range page.Records {
+				seen[row.ID] = row
+			}
+		}
+		var nextRows map[string]Row
+		if usesPrevious {
+			nextRows = seen
+		}
+		t.checkRows(ctx, tableName, page.Records, previousRows, nextRows, watchers, active, &result)
+
+		if page.Offset == "" {
+			break
+		}
+		offset = page.Offset
+	}
+
+	if usesPrevious {
+		t.Lock()
+		t.previousRows[tableName] = seen
+		t.Unlock()
+	}
+	t.trackEmpty(tableName, result.Scanned)
+	return result, nil
+}
+
+// trackEmpty Count consecutive polls where a table had no rows, calling OnTableEmpty when it reaches EmptyTableCycles
+func (t *Watcher) trackEmpty(tableName string, rowCount int) {
+	threshold, ok := t.EmptyTableCycles[tableName]
+	if !ok {
+		threshold = DefaultEmptyTableCycles
+	}
+
+	t.Lock()
+	if rowCount > 0 {
+		t.emptyCycles[tableName] = 0
+		t.Unlock()
+		return
+	}
+	t.emptyCycles[tableName]++
+	cycles := t.emptyCycles[tableName]
+	onTableEmpty := t.OnTableEmpty
+	t.Unlock()
+
+	if onTableEmpty != nil && cycles == threshold {
+		onTableEmpty(tableName, cycles)
+	}
+}
+
+// dispatch runs the watcher's action function on a row in a new goroutine.
+// Returns false if the watcher is draining and nothing was started
+func (t *Watcher) dispatch(ctx context.Context, row Row, watcher *watch) bool {
+	// Add to list of rows we are ignoring
+	t.Lock()
+	if t.draining {
+		t.Unlock()
+		return false
+	}
+	key := dispatchKey(&row, watcher)
+	t.IgnoreRows[key] = struct{}{}
+	if watcher.minInterval > 0 {
+		t.nextRunAllowed[intervalKey(&row, watcher)] = time.Now().Add(watcher.minInterval)
+	}
+	t.inFlight++
+	t.Unlock()
+
+	t.running.Add(1)
+	go func() {
+		defer t.running.Done()
+		// Every request made for this dispatch carries the same request ID.
+		// Bookkeeping writes use requestCtx so they still happen once ctx is canceled
+		requestID := newRequestID()
+		requestCtx := WithRequestID(context.Background(), requestID)
+		actionFunctionCtx, actionFunctionCancel := context.WithCancel(WithRequestID(ctx, requestID))
+
+		// Remove from rows we ignore once done, even on panic
+		defer func() {
+			actionFunctionCancel()
+			t.Lock()
+			if t.DispatchCooldown > 0 {
+				t.recentlyDispatched[cooldownKey(&row, watcher)] = time.Now()
+			}
+			delete(t.IgnoreRows, key)
+			t.inFlight--
+			t.notifyStateChanged()
+			t.Unlock()
+		}()
+
+		// Wait for a free slot
+		release, err := t.acquireSlots(actionFunctionCtx, watcher)
+		if err != nil {
+			return
+		}
+		defer release()
+
+		// Show who is working on this row
+		defer t.markProcessing(requestCtx, watcher.tableName, row.ID)()
+
+		// Cancel context if fieldName =/= triggerValue
+		started := time.Now()
+		canceler := &actionCanceler{cancel: actionFunctionCancel}
+		if !t.DisableCancelWatch && watcher.cancelable() {
+			go t.watchForCancel(actionFunctionCtx, &row, watcher, started, canceler)
+		}
+		if t.MaxActionDuration > 0 {
+			timeout := time.AfterFunc(t.MaxActionDuration, func() {
+				detail := fmt.Sprintf("exceeded MaxActionDuration of %s, the action is probably stuck", t.MaxActionDuration)
+				t.logEvent(Event{Type: EventTimedOut, TableName: watcher.tableName, RecordID: row.ID, Detail: detail, Duration: time.Since(started)})
+				t.notifyCancel(watcher.tableName, row.ID, watcher.value(&row), started)
+				canceler.cancelWithReason(detail)
+			})
+			defer timeout.Stop()
+		}
+
+		// Call action
+		t.logEvent(Event{Type: EventStarted, TableName: watcher.tableName, RecordID: row.ID, Detail: "request " + requestID})
+		defer func() {
+			event := Event{Type: EventFinished, TableName: watcher.tableName, RecordID: row.ID, Duration: time.Since(started)}
+			if err := actionFunctionCtx.Err(); err != nil {
+				event.Type = EventCanceled
+				event.Detail = canceler.detail(err)
+			}
+			t.logEvent(event)
+		}()
+		triggerValue := watcher.value(&row)
+		t.withMiddleware(watcher.actionFunction)(actionFunctionCtx, t, watcher.tableName, &row)
+		if watcher.verifyTransition && actionFunctionCtx.Err() == nil {
+			t.verifyTransition(requestCtx, row.ID, watcher, triggerValue)
+		}
+	}()
+
+	return true
+}
+
+// cooldownKey Get the key of a row and watcher in the recently dispatched cache
+func cooldownKey(row *Row, watcher *watch) string {
+	return fmt.Sprintf("%s/%s/%d/%s", watcher.tableName, row.ID, watcher.id, watcher.value(row))
+}
+
+// coolingDown Check if the row's action for this watcher finished less than DispatchCooldown ago
+func (t *Watcher) coolingDown(row *Row, watcher *watch) bool {
+	if t.DispatchCooldown <= 0 {
+		return false
+	}
+	t.Lock()
+	defer t.Unlock()
+	finished, ok := t.recentlyDispatched[cooldownKey(row, watcher)]
+	return ok && time.Since(finished) < t.DispatchCooldown
+}
+
+// intervalKey Get the key of a row and watcher in nextRunAllowed
+func intervalKey(row *Row, watcher *watch) string {
+	return fmt.Sprintf("%s/%s/%d", watcher.tableName, row.ID, watcher.id)
+}
+
+// withinMinInterval Check if the row was dispatched to this watcher less than its WithMinInterval ago
+func (t *Watcher) withinMinInterval(row *Row, watcher *watch) bool {
+	if watcher.minInterval <= 0 {
+		return false
+	}
+	t.Lock()
+	defer t.Unlock()
+	allowed, ok := t.nextRunAllowed[intervalKey(row, watcher)]
+	return ok && time.Now().Before(allowed)
+}
+
+// pruneCooldowns Forget rows whose cooldown or min interval has passed, must hold the lock
+func (t *Watcher) pruneCooldowns() {
+	for key, finished := range t.recentlyDispatched {
+		if time.Since(finished) >= t.DispatchCooldown {
+			delete(t.recentlyDispatched, key)
+		}
+	}
+	now := time.Now()
+	for key, allowed := range t.nextRunAllowed {
+		if !now.Before(allowed) {
+			delete(t.nextRunAllowed, key)
+		}
+	}
+}
+
+// enabledWatchers Get watchers without the ones whose enabled config key is set to "false"
+func (t *Watcher) enabledWatchers(watchers []*watch) ([]*watch, error) {
+	enabled := make([]*watch, 0, len(watchers))
+	var config map[string]string
+	for _, watcher := range watchers {
+		if watcher.enabledConfigKey == "" {
+			enabled = append(enabled, watcher)
+			continue
+		}
+		// Only fetch the config table once per cycle
+		if config == nil {
+			var err error
+			config, err = t.GetAllConfig()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if value, ok := config[watcher.enabledConfigKey]; ok && strings.EqualFold(strings.TrimSpace(value), "false") {
+			continue
+		}
+		enabled = append(enabled, watcher)
+	}
+
+	return enabled, nil
+}
+
+// actionCanceler cancels an action's context, remembering why for the canceled event
+type actionCanceler struct {
+	sync.Mutex
+	cancel context.CancelFunc
+	reason string
+}
+
+// cancelWithReason Cancel the action, the first reason given is kept
+func (c *actionCanceler) cancelWithReason(reason string) {
+	c.Lock()
+	if c.reason == "" {
+		c.reason = reason
+	}
+	c.Unlock()
+	c.cancel()
+}
+
+// detail Get why the action was canceled, falling back to the context's error
+func (c *actionCanceler) detail(err error) string {
+	c.Lock()
+	defer c.Unlock()
+	if c.reason != "" {
+		return c.reason
+	}
+	return err.Error()
+}
+
+// notifyCancel Call OnCancel if set
+func (t *Watcher) notifyCancel(tableName, recordID, value string, started time.Time) {
+	if t.OnCancel != nil {
+		t.OnCancel(tableName, recordID, value, time.Since(started))
+	}
+}
+
+// watchForCancel watches a row if it changes to a cancel value or runs longer than its max runtime field, if it does, cancels the context
+func (t *Watcher) watchForCancel(ctx context.Context, row *Row, watcher *watch, started time.Time, canceler *actionCanceler) {
+	sem := t.cancelWatchSlots()
+	for {
+		if sem != nil && sem.acquire(ctx) != nil {
+			return
+		}
+		rowUpdated, err := t.GetRowContext(ctx, watcher.tableName, row.ID)
+		if sem != nil {
+			sem.release()
+		}
+		if err != nil {
+			return
+		}
+		value := watcher.value(rowUpdated)
+		if watcher.canceled(rowUpdated) {
+			// Cancel that action function
+			canceler.cancelWithReason(fmt.Sprintf("%s changed to %s", watcher.fieldName, value))
+			t.notifyCancel(watcher.tableName, row.ID, value, started)
 			return
 		}
-		value := rowUpdated.GetFieldString(watcher.fieldName)
-		for _, cancelValue := range watcher.cancelValues {
-			if value == cancelValue {
-				// Cancel that action function
-				actionFunctionCancel()
+		if watcher.maxRuntimeField != "" {
+			if maxRuntime, err := rowUpdated.GetFieldDuration(watcher.maxRuntimeField); err == nil && maxRuntime > 0 && time.Since(started) > maxRuntime {
+				canceler.cancelWithReason(fmt.Sprintf("exceeded max runtime of %s", maxRuntime))
+				t.notifyCancel(watcher.tableName, row.ID, value, started)
 				return
 			}
 		}
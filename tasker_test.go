@@ -2,8 +2,14 @@ package airtablewatcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -182,3 +188,578 @@ func TestConcurrentJob(t *testing.T) {
 	// Start tasker
 	watcher.Start(context.Background())
 }
+
+func TestEnabledConfigKey(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	configID := fake.AddRecord("Config", map[string]interface{}{"Key": "watch.tasks.enabled", "Value": "false"})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan struct{}, 10)
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		ran <- struct{}{}
+	}, WithEnabledConfigKey("watch.tasks.enabled"))
+
+	// Disabled
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	select {
+	case <-ran:
+		t.Errorf("Function ran while disabled")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	// Enabled
+	fake.SetField("Config", configID, "Value", "true")
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Errorf("Function did not run while enabled")
+	}
+}
+
+func TestWaitIdle(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.PollInterval = time.Millisecond * 10
+
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		time.Sleep(time.Millisecond * 50)
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	go watcher.Start(ctx)
+
+	if err := watcher.WaitIdle(ctx); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if fake.Record("Tasks", id)["State"] != "Done" {
+		t.Errorf("WaitIdle returned before the action finished")
+	}
+}
+
+func TestOnShutdown(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.PollInterval = time.Millisecond * 10
+
+	started := make(chan struct{})
+	actionDone := false
+	shutdowns := 0
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(time.Millisecond * 20)
+		actionDone = true
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+	}, WithOnShutdown(func(watcher *Watcher, tableName string) {
+		if !actionDone {
+			t.Errorf("Shutdown ran before the action drained")
+		}
+		shutdowns++
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Start(ctx)
+	}()
+	<-started
+	cancel()
+	<-done
+
+	if shutdowns != 1 {
+		t.Errorf("Expected shutdown to run once, ran %d times", shutdowns)
+	}
+}
+
+func TestDrainAndStop(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.PollInterval = time.Millisecond * 10
+
+	started := make(chan struct{}, 10)
+	canceled := false
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		started <- struct{}{}
+		select {
+		case <-ctx.Done():
+			canceled = true
+		case <-time.After(time.Millisecond * 100):
+		}
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- watcher.Start(context.Background())
+	}()
+	<-started
+
+	// Let it finish, without picking up the row again
+	if err := watcher.DrainAndStop(context.Background()); err != nil {
+		t.Errorf(err.Error())
+	}
+	if canceled {
+		t.Errorf("Running action was canceled")
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Start should return nil after draining: %s", err)
+	}
+	if len(started) != 0 || fake.Record("Tasks", id)["State"] != "ToDo" {
+		t.Errorf("Row was dispatched again while draining")
+	}
+}
+
+func TestDrainAndStopTimeout(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.PollInterval = time.Millisecond * 10
+
+	started := make(chan struct{}, 10)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		started <- struct{}{}
+		<-ctx.Done()
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- watcher.Start(context.Background())
+	}()
+	<-started
+
+	// The action never finishes by itself, so giving up on the drain cancels it and Start returns
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if err := watcher.DrainAndStop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the drain to time out, got %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start should return nil after draining: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Start did not return after the drain timed out")
+	}
+}
+
+func TestNextPollDelay(t *testing.T) {
+	watcher := &Watcher{PollInterval: time.Minute}
+	now := time.Date(2020, 1, 1, 10, 30, 15, 0, time.UTC)
+	if delay := watcher.nextPollDelay(now); delay != time.Minute {
+		t.Errorf("Unaligned delay should be PollInterval, got %s", delay)
+	}
+	watcher.AlignPolls = true
+	if delay := watcher.nextPollDelay(now); delay != time.Second*45 {
+		t.Errorf("Expected to wait until the next minute, got %s", delay)
+	}
+	watcher.PollInterval = time.Hour
+	if delay := watcher.nextPollDelay(now); delay != time.Minute*29+time.Second*45 {
+		t.Errorf("Expected to wait until the next hour, got %s", delay)
+	}
+}
+
+func TestRegisterWhileRunning(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.AddRecord("Other", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.PollInterval = time.Millisecond
+
+	fired := make(chan string, 10)
+	action := func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+		fired <- tableName
+	}
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, action)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Start(ctx)
+	}()
+
+	// Register, read and write while polls run
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		watcher.RegisterFunction("Other", "State", []string{"ToDo"}, action)
+	}()
+	go func() {
+		defer wg.Done()
+		watcher.GetRow("Tasks", id)
+		watcher.SetRow("Tasks", id, map[string]interface{}{"Note": "edited"})
+	}()
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		select {
+		case tableName := <-fired:
+			seen[tableName] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Expected both functions to fire, got %v", seen)
+		}
+	}
+	cancel()
+	<-done
+}
+
+func TestNoRetriggerWhileRunning(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	watcher := newFakeWatcher(t, fake)
+
+	var calls int32
+	release := make(chan struct{})
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+	})
+	if err := watcher.SetRow("Tasks", id, map[string]interface{}{"State": "ToDo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The row stays in the trigger state for every poll while its action runs
+	for i := 0; i < 5; i++ {
+		if _, err := watcher.pollCycle(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, running := watcher.IgnoreRows["Tasks/"+id]; !running {
+		t.Errorf("Expected the row to be kept by table and record ID while running")
+	}
+	close(release)
+	watcher.running.Wait()
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Errorf("Expected the action to run once, ran %d times", calls)
+	}
+	if len(watcher.IgnoreRows) != 0 {
+		t.Errorf("Row should no longer be ignored once its action returned")
+	}
+}
+
+func TestDrainBurst(t *testing.T) {
+	watcher := &Watcher{DrainMaxBurst: 2}
+	burst := 0
+	if watcher.drainBurst(true, &burst) {
+		t.Errorf("Should wait without DrainMode")
+	}
+	watcher.DrainMode = true
+	if !watcher.drainBurst(true, &burst) || !watcher.drainBurst(true, &burst) {
+		t.Errorf("Should poll straight away while actions fire")
+	}
+	if watcher.drainBurst(true, &burst) {
+		t.Errorf("Should wait after DrainMaxBurst polls")
+	}
+	if !watcher.drainBurst(true, &burst) || watcher.drainBurst(false, &burst) || burst != 0 {
+		t.Errorf("Should wait once nothing fires")
+	}
+}
+
+func TestUse(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	calls := []string{}
+	record := func(name string) Middleware {
+		return func(next ActionFunction) ActionFunction {
+			return func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+				calls = append(calls, name+" before")
+				next(ctx, watcher, tableName, row)
+				calls = append(calls, name+" after")
+			}
+		}
+	}
+	watcher.Use(record("first"))
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		calls = append(calls, "action")
+	})
+	watcher.Use(record("second"))
+
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"first before", "second before", "action", "second after", "first after"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Incorrect middleware order %v", calls)
+	}
+}
+
+func TestMaxRuntimeField(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "MaxRuntime": 0.05})
+	watcher := newFakeWatcher(t, fake)
+	watcher.PollInterval = time.Millisecond * 20
+
+	canceled := make(chan bool, 1)
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		select {
+		case <-ctx.Done():
+			canceled <- true
+		case <-time.After(time.Second * 2):
+			canceled <- false
+		}
+	}, WithMaxRuntimeField("MaxRuntime"))
+
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !<-canceled {
+		t.Errorf("Action was not canceled after its max runtime")
+	}
+	watcher.running.Wait()
+	events := watcher.RecentEvents(1)
+	if len(events) != 1 || events[0].Type != EventCanceled || !strings.Contains(events[0].Detail, "max runtime") {
+		t.Errorf("Incorrect canceled event %+v", events)
+	}
+}
+
+func TestOnCancel(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.PollInterval = time.Millisecond * 20
+
+	type cancel struct {
+		recordID, value string
+		elapsed         time.Duration
+	}
+	cancels := make(chan cancel, 1)
+	watcher.OnCancel = func(tableName, recordID, value string, elapsed time.Duration) {
+		cancels <- cancel{recordID, value, elapsed}
+	}
+	started := make(chan bool, 1)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		started <- true
+		<-ctx.Done()
+	}, "Stop")
+
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	fake.SetField("Tasks", id, "State", "Stop")
+	select {
+	case got := <-cancels:
+		if got.recordID != id || got.value != "Stop" || got.elapsed <= 0 {
+			t.Errorf("Incorrect cancel %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("OnCancel was not called")
+	}
+	watcher.running.Wait()
+}
+
+// gatedTransport holds back requests for later pages until gate is closed
+type gatedTransport struct {
+	base http.RoundTripper
+	gate chan struct{}
+}
+
+func (g *gatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("offset") != "" {
+		select {
+		case <-g.gate:
+		case <-time.After(time.Second):
+		}
+	}
+	return g.base.RoundTrip(req)
+}
+
+func TestStreamPages(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.PageSize = 2
+	for i := 0; i < 5; i++ {
+		fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	}
+	watcher := newFakeWatcher(t, fake)
+	watcher.StreamPages = true
+	gate := make(chan struct{})
+	watcher.Transport = &gatedTransport{base: watcher.Transport, gate: gate}
+
+	// The first action opens the gate, so later pages only arrive quickly if rows are dispatched per page
+	var once sync.Once
+	runs := make(chan string, 10)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		once.Do(func() { close(gate) })
+		runs <- row.ID
+	})
+	started := time.Now()
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 5 {
+		t.Errorf("Expected 5 runs, got %d", len(runs))
+	}
+	if fake.ListRequests("Tasks") != 3 {
+		t.Errorf("Expected 3 pages, got %d", fake.ListRequests("Tasks"))
+	}
+	if time.Since(started) > time.Millisecond*500 {
+		t.Errorf("Rows were not dispatched before the next page was fetched")
+	}
+}
+
+func TestOnTableEmpty(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	watcher := newFakeWatcher(t, fake)
+	watcher.EmptyTableCycles = map[string]int{"Tasks": 2}
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, recordRuns(make(chan string, 10)))
+
+	alerts := []int{}
+	watcher.OnTableEmpty = func(tableName string, cycles int) {
+		alerts = append(alerts, cycles)
+	}
+	poll := func() {
+		t.Helper()
+		if _, err := watcher.pollCycle(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	poll()
+	if len(alerts) != 0 {
+		t.Errorf("Alerted before the threshold")
+	}
+	poll()
+	poll()
+	if !reflect.DeepEqual(alerts, []int{2}) {
+		t.Errorf("Expected a single alert after 2 cycles, got %v", alerts)
+	}
+
+	// Rows coming back resets the count
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	poll()
+	fake.DeleteRecord("Tasks", id)
+	poll()
+	poll()
+	if len(alerts) != 2 {
+		t.Errorf("Expected a second alert once the table emptied again, got %v", alerts)
+	}
+}
+
+func TestTablePolicy(t *testing.T) {
+	watcher := &Watcher{AllowedTables: []string{"Tasks", "Secrets"}, DeniedTables: []string{"Secrets"}}
+	action := func(ctx context.Context, t *Watcher, tableName string, row *Row) {}
+
+	if err := watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, action); err != nil {
+		t.Errorf("Expected allowed table to register: %s", err)
+	}
+	if err := watcher.RegisterFunction("Secrets", "State", []string{"ToDo"}, action); !errors.Is(err, ErrTableNotAllowed) {
+		t.Errorf("Expected denied table to fail, got %v", err)
+	}
+	if err := watcher.RegisterPresence("Other", "State", action); !errors.Is(err, ErrTableNotAllowed) {
+		t.Errorf("Expected table outside the allowlist to fail, got %v", err)
+	}
+	if len(watcher.watchers) != 1 {
+		t.Errorf("Expected only the allowed watch to be added, got %d", len(watcher.watchers))
+	}
+}
+
+func TestSkipCancelWatch(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.AddRecord("Jobs", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	transport := &inFlightTransport{base: watcher.Transport}
+	watcher.Transport = transport
+	watcher.PollInterval = time.Millisecond * 10
+
+	release := make(chan struct{})
+	action := func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		<-release
+	}
+	// Nothing can cancel this one
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, action)
+	// And cancel watching is off for this one
+	watcher.RegisterFunction("Jobs", "State", []string{"ToDo"}, action, "Stop")
+	watcher.DisableCancelWatch = true
+
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	watcher.running.Wait()
+
+	transport.Lock()
+	defer transport.Unlock()
+	if transport.max != 0 {
+		t.Errorf("Expected no rows to be re-read while actions ran")
+	}
+}
+
+func TestMaxActionDuration(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.MaxActionDuration = time.Millisecond * 20
+	notified := make(chan string, 1)
+	watcher.OnCancel = func(tableName, recordID, value string, elapsed time.Duration) {
+		notified <- value
+	}
+
+	canceled := false
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		select {
+		case <-ctx.Done():
+			canceled = true
+		case <-time.After(time.Second):
+		}
+	})
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !canceled {
+		t.Errorf("Action was not canceled after MaxActionDuration")
+	}
+	timedOut := 0
+	for _, event := range watcher.RecentEvents(0) {
+		if event.Type == EventTimedOut {
+			timedOut++
+		}
+		if event.Type == EventCanceled && !strings.Contains(event.Detail, "MaxActionDuration") {
+			t.Errorf("Incorrect canceled detail %s", event.Detail)
+		}
+	}
+	if timedOut != 1 {
+		t.Errorf("Expected a single timed out event, got %d", timedOut)
+	}
+	select {
+	case value := <-notified:
+		if value != "ToDo" {
+			t.Errorf("Expected OnCancel with the dispatched value, got %s", value)
+		}
+	default:
+		t.Errorf("OnCancel was not called")
+	}
+}
@@ -26,3 +26,19 @@ func Example() {
 	// Start tasker
 	tasker.Start(context.Background())
 }
+
+// Process a queue-like table in the order rows were added, using an autoNumber field
+func ExampleOrderByNumber() {
+	tasker, err := NewWatcher(os.Getenv("AIRTABLE_KEY"), os.Getenv("AIRTABLE_BASE"))
+	if err != nil {
+		return
+	}
+	tasker.DispatchOrder = OrderByNumber("Number")
+	tasker.RegisterFunction("Queue", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		number, _ := row.GetFieldInt("Number")
+		fmt.Printf("Processing queue item %d\n", number)
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+	})
+
+	tasker.Start(context.Background())
+}
@@ -0,0 +1,51 @@
+package airtablewatcher
+
+import (
+	"context"
+	"fmt"
+)
+
+// TransactionError is returned by BestEffortTransaction when the create failed part way through
+type TransactionError struct {
+	// Why the create failed
+	Err error
+	// Records that were created then deleted again
+	RolledBack []string
+	// Records that were created and could not be deleted, they are still in the table
+	NotRolledBack []string
+	// Why the rollback failed, nil if everything was rolled back
+	RollbackErr error
+}
+
+// Error implements error
+func (e *TransactionError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("%s, rollback failed leaving %d records: %s", e.Err, len(e.NotRolledBack), e.RollbackErr)
+	}
+	return fmt.Sprintf("%s, rolled back %d records", e.Err, len(e.RolledBack))
+}
+
+// Unwrap returns the create error
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// BestEffortTransaction Create rows in a table, all or nothing as far as possible.
+// Airtable batches are not transactional, so if a batch fails the records already created are deleted again.
+// The table's CreateDefaults are filled in like BatchCreateRows.
+// Returns the created record IDs in order, or a *TransactionError reporting what could and couldn't be undone
+func (t *Watcher) BestEffortTransaction(tableName string, rows []map[string]interface{}) ([]string, error) {
+	ctx := context.Background()
+	createdRows, err := t.BatchCreateRows(tableName, rows)
+	created := recordIDs(createdRows)
+	if err == nil {
+		return created, nil
+	}
+
+	transactionErr := &TransactionError{Err: err}
+	transactionErr.RolledBack, transactionErr.RollbackErr = t.deleteRecords(ctx, tableName, created)
+	if transactionErr.RollbackErr != nil {
+		transactionErr.NotRolledBack = created[len(transactionErr.RolledBack):]
+	}
+	return nil, transactionErr
+}
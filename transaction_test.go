@@ -0,0 +1,48 @@
+package airtablewatcher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBestEffortTransaction(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	watcher := newFakeWatcher(t, fake)
+
+	rows := []map[string]interface{}{}
+	for i := 0; i < MaxBatchSize+2; i++ {
+		rows = append(rows, map[string]interface{}{"Name": "Subtask"})
+	}
+
+	// Everything is created
+	ids, err := watcher.BestEffortTransaction("Tasks", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != len(rows) || len(fake.RecordIDs("Tasks")) != len(rows) {
+		t.Errorf("Expected %d rows created, got %d", len(rows), len(ids))
+	}
+
+	// The second batch fails, so the first is deleted again
+	fake.FailAfter(1, 422, "INVALID_VALUE_FOR_COLUMN")
+	_, err = watcher.BestEffortTransaction("Other", rows)
+	transactionErr := &TransactionError{}
+	if !errors.As(err, &transactionErr) {
+		t.Fatalf("Expected a TransactionError, got %v", err)
+	}
+	if len(transactionErr.RolledBack) != MaxBatchSize || len(transactionErr.NotRolledBack) != 0 || transactionErr.RollbackErr != nil {
+		t.Errorf("Incorrect rollback %+v", transactionErr)
+	}
+	if len(fake.RecordIDs("Other")) != 0 {
+		t.Errorf("Rows were left behind")
+	}
+
+	// The rollback fails too
+	fake.FailAfter(1, 422, "INVALID_VALUE_FOR_COLUMN")
+	fake.FailNext(403, "INVALID_PERMISSIONS")
+	_, err = watcher.BestEffortTransaction("Third", rows)
+	if !errors.As(err, &transactionErr) || len(transactionErr.NotRolledBack) != MaxBatchSize || transactionErr.RollbackErr == nil {
+		t.Errorf("Expected an incomplete rollback, got %v", err)
+	}
+}
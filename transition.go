@@ -0,0 +1,46 @@
+package airtablewatcher
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// Template tokens expanded by TransitionRow
+const (
+	// TokenNow is replaced with the current UTC time in AirtableDateFormat
+	TokenNow = "{{now}}"
+	// TokenHost is replaced with the hostname of this machine
+	TokenHost = "{{host}}"
+	// TokenRecordID is replaced with the ID of the record being written
+	TokenRecordID = "{{id}}"
+)
+
+// TransitionRow Set fields for a row like SetRow, expanding template tokens in string values at write time.
+// For example {"State": "Done", "CompletedAt": "{{now}}", "CompletedBy": "{{host}}"}.
+// See TokenNow, TokenHost and TokenRecordID
+func (t *Watcher) TransitionRow(tableName, recordID string, set map[string]interface{}) error {
+	return t.SetRow(tableName, recordID, expandTemplates(set, recordID, time.Now()))
+}
+
+// expandTemplates Get a copy of fields with template tokens in string values replaced
+func expandTemplates(fields map[string]interface{}, recordID string, now time.Time) map[string]interface{} {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	replacer := strings.NewReplacer(
+		TokenNow, now.UTC().Format(AirtableDateFormat),
+		TokenHost, host,
+		TokenRecordID, recordID,
+	)
+
+	expanded := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if valueString, ok := value.(string); ok {
+			value = replacer.Replace(valueString)
+		}
+		expanded[key] = value
+	}
+	return expanded
+}
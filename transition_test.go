@@ -0,0 +1,28 @@
+package airtablewatcher
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExpandTemplates(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	host, _ := os.Hostname()
+	expanded := expandTemplates(map[string]interface{}{
+		"State":       "Done",
+		"CompletedAt": "{{now}}",
+		"CompletedBy": "{{host}} ({{id}})",
+		"Count":       3.0,
+	}, "recAAAAAAAAAAAAAA", now)
+
+	if expanded["State"] != "Done" || expanded["Count"] != 3.0 {
+		t.Errorf("Non template values should not change")
+	}
+	if expanded["CompletedAt"] != "2020-01-02T03:04:05.000Z" {
+		t.Errorf("Incorrect now: %v", expanded["CompletedAt"])
+	}
+	if expanded["CompletedBy"] != host+" (recAAAAAAAAAAAAAA)" {
+		t.Errorf("Incorrect host: %v", expanded["CompletedBy"])
+	}
+}
@@ -0,0 +1,113 @@
+package airtablewatcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// watcherTransport wraps the airtable client's transport so the watcher can observe every response
+type watcherTransport struct {
+	watcher *Watcher
+}
+
+// RoundTrip implements http.RoundTripper
+func (w *watcherTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := w.watcher.requestLimiter.wait(req.Context(), w.watcher.RequestsPerSecond); err != nil {
+		return nil, err
+	}
+	if err := w.watcher.waitForBudget(req.Context()); err != nil {
+		return nil, err
+	}
+
+	breaker := w.watcher.Breaker
+	if breaker != nil && !breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	// Copy the request rather than modify it, as RoundTrippers must not
+	if id := RequestID(req.Context()); id != "" {
+		withID := *req
+		withID.Header = http.Header{}
+		for key, values := range req.Header {
+			withID.Header[key] = values
+		}
+		withID.Header.Set(RequestIDHeader, id)
+		req = &withID
+	}
+
+	base := w.watcher.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if breaker != nil {
+		failed := err != nil || resp.StatusCode >= 500
+		if err != nil && req.Context().Err() != nil {
+			// Canceled or timed out by the caller, not a failure of airtable
+			breaker.release()
+		} else if breaker.record(!failed) && w.watcher.OnCircuitOpen != nil {
+			w.watcher.OnCircuitOpen(breakerError(resp, err))
+		}
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if w.watcher.OnRateLimit != nil {
+			w.watcher.OnRateLimit(tableFromPath(req.URL.Path), parseRetryAfter(resp.Header.Get("Retry-After")))
+		}
+	}
+
+	if concurrency := w.watcher.Concurrency; concurrency != nil {
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			concurrency.OnRateLimit()
+		case resp.StatusCode < 300:
+			concurrency.OnSuccess()
+		}
+	}
+
+	return resp, nil
+}
+
+// tableFromPath Get the table name from an API path like /v0/appXXX/Tasks/recXXX, empty if there isn't one
+func tableFromPath(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) < 3 || parts[0] != "v0" || parts[1] == "meta" || parts[1] == "bases" {
+		return ""
+	}
+	tableName, err := url.PathUnescape(parts[2])
+	if err != nil {
+		return parts[2]
+	}
+	return tableName
+}
+
+// parseRetryAfter Parse a Retry-After header in either seconds or HTTP date form, 0 if missing or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// breakerError Describe the failure that opened the circuit
+func breakerError(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("airtable returned %s", resp.Status)
+}
@@ -0,0 +1,56 @@
+package airtablewatcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestOnRateLimit(t *testing.T) {
+	limited := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited {
+			limited = false
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"records": []}`))
+	}))
+	defer server.Close()
+
+	watcher, err := NewWatcher(testAirtableKey, testAirtableBase)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	target, _ := url.Parse(server.URL)
+	watcher.Transport = &redirectTransport{target: target}
+	// Don't wait around in the test
+	watcher.AirtableClient.ShouldRetryIfRateLimited = false
+
+	var gotTable string
+	var gotRetryAfter time.Duration
+	watcher.OnRateLimit = func(tableName string, retryAfter time.Duration) {
+		gotTable = tableName
+		gotRetryAfter = retryAfter
+	}
+	watcher.GetRows("My Tasks")
+
+	if gotTable != "My Tasks" || gotRetryAfter != time.Second*3 {
+		t.Errorf("Incorrect rate limit callback: %q %s", gotTable, gotRetryAfter)
+	}
+}
+
+// redirectTransport sends every request to a test server instead of airtable
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (r *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,250 @@
+package airtablewatcher
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AllTables is the table name of RegisterGlobal watchers
+const AllTables = "*"
+
+// Comparison is an operator used by RegisterThreshold
+type Comparison string
+
+// Comparisons
+const (
+	GreaterThan        Comparison = ">"
+	GreaterThanOrEqual Comparison = ">="
+	LessThan           Comparison = "<"
+	LessThanOrEqual    Comparison = "<="
+	Equal              Comparison = "=="
+)
+
+// compare Check "a op b"
+func (c Comparison) compare(a, b float64) (bool, error) {
+	switch c {
+	case GreaterThan:
+		return a > b, nil
+	case GreaterThanOrEqual:
+		return a >= b, nil
+	case LessThan:
+		return a < b, nil
+	case LessThanOrEqual:
+		return a <= b, nil
+	case Equal:
+		return a == b, nil
+	}
+	return false, fmt.Errorf("unknown comparison %q", string(c))
+}
+
+// RegisterTransition Register a function to run only when fieldName changes from one of fromValues to one of toValues
+// between two polls.
+// Rows held back when the change happens, e.g. by WithMinAge or a guard, still trigger once they can be dispatched.
+// Rows already in a toValue when the watcher starts do not trigger
+func (t *Watcher) RegisterTransition(tableName, fieldName string, fromValues, toValues []string, actionFunction ActionFunction, options ...WatchOption) error {
+	return t.addWatch(watch{
+		tableName:      tableName,
+		fieldName:      fieldName,
+		triggerValues:  toValues,
+		actionFunction: actionFunction,
+		usesPrevious:   true,
+		trigger: func(row, previous *Row) bool {
+			return previous != nil &&
+				containsString(fromValues, previous.GetFieldString(fieldName)) &&
+				containsString(toValues, row.GetFieldString(fieldName))
+		},
+	}, options...)
+}
+
+// RegisterThreshold Register a function to run when a numeric field satisfies "field op value", e.g. Priority >= 8.
+// Works with number, count and rollup fields, e.g. a count of open subtasks Equal 0 once they are all done.
+// The function is canceled when the condition no longer holds. Rows where the field is not a number never trigger
+func (t *Watcher) RegisterThreshold(tableName, fieldName string, op Comparison, value float64, actionFunction ActionFunction, options ...WatchOption) error {
+	if _, err := op.compare(0, value); err != nil {
+		return err
+	}
+	holds := func(row *Row) bool {
+		fieldValue, err := row.GetFieldFloat(fieldName)
+		if err != nil {
+			return false
+		}
+		matched, _ := op.compare(fieldValue, value)
+		return matched
+	}
+
+	return t.addWatch(watch{
+		tableName:      tableName,
+		fieldName:      fieldName,
+		actionFunction: actionFunction,
+		trigger: func(row, previous *Row) bool {
+			return holds(row)
+		},
+		cancel: func(row *Row) bool {
+			return !holds(row)
+		},
+	}, options...)
+}
+
+// RegisterPresence Register a function to run when fieldName is filled in, going from empty to any value between two polls.
+// The function is canceled if the field is emptied again. A row held back when it is filled in, e.g. by WithMinAge,
+// still triggers once it can be dispatched. Rows already filled in when the watcher starts do not trigger
+func (t *Watcher) RegisterPresence(tableName, fieldName string, actionFunction ActionFunction, options ...WatchOption) error {
+	return t.addWatch(watch{
+		tableName:      tableName,
+		fieldName:      fieldName,
+		actionFunction: actionFunction,
+		usesPrevious:   true,
+		trigger: func(row, previous *Row) bool {
+			return previous != nil && fieldEmpty(previous.GetField(fieldName)) && !fieldEmpty(row.GetField(fieldName))
+		},
+		cancel: func(row *Row) bool {
+			return fieldEmpty(row.GetField(fieldName))
+		},
+	}, options...)
+}
+
+// fieldEmpty Check if a field value is empty, airtable leaves empty fields out entirely
+func fieldEmpty(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+// RegisterExpiry Register a function to run when a row has had one of values in fieldName for longer than after,
+// e.g. to escalate tasks left in "ToDo" for a day. How long is read from timestampField, typically a
+// "Last modified time" field watching fieldName. Rows missing the timestamp fall back to when they were created,
+// and never fire if that is unknown too.
+// The function is canceled if the field changes to another value. Like RegisterFunction the row keeps
+// triggering every poll until the action moves it on, see WithCompletion or RegisterTransition otherwise
+func (t *Watcher) RegisterExpiry(tableName, fieldName string, values []string, after time.Duration, timestampField string, actionFunction ActionFunction, options ...WatchOption) error {
+	return t.addWatch(watch{
+		tableName:      tableName,
+		fieldName:      fieldName,
+		triggerValues:  values,
+		actionFunction: actionFunction,
+		trigger: func(row, previous *Row) bool {
+			if !containsString(values, row.GetFieldString(fieldName)) {
+				return false
+			}
+			entered := row.GetFieldTime(timestampField)
+			if entered.Equal(DefaultBlankTime) {
+				entered = row.CreatedTime
+			}
+			if entered.IsZero() || entered.Equal(DefaultBlankTime) {
+				return false
+			}
+			return time.Since(entered) > after
+		},
+		cancel: func(row *Row) bool {
+			return !containsString(values, row.GetFieldString(fieldName))
+		},
+	}, options...)
+}
+
+// RegisterCompound Register a function to run when several fields joined with separator, in the order of fieldNames,
+// equal one of triggerValues, e.g. fields Region and Status with separator "|" matching "US|Approved".
+// Otherwise it works like RegisterFunctionWithOptions, WithCancelValues and WithNormalizer apply to the joined value
+func (t *Watcher) RegisterCompound(tableName string, fieldNames []string, separator string, triggerValues []string, actionFunction ActionFunction, options ...WatchOption) error {
+	return t.addWatch(watch{
+		tableName:      tableName,
+		fieldName:      strings.Join(fieldNames, separator),
+		triggerValues:  triggerValues,
+		actionFunction: actionFunction,
+		combine: func(row *Row) string {
+			values := make([]string, 0, len(fieldNames))
+			for _, fieldName := range fieldNames {
+				values = append(values, row.GetFieldString(fieldName))
+			}
+			return strings.Join(values, separator)
+		},
+	}, options...)
+}
+
+// RegisterGlobal Register a function to run on every row of every table other functions are registered on, every poll,
+// e.g. for an audit log. It runs alongside the tables' own functions rather than competing with them for rows,
+// with the same concurrency limits, cooldown and active hours. The action is given the row's real table name
+func (t *Watcher) RegisterGlobal(actionFunction ActionFunction, options ...WatchOption) error {
+	return t.addWatch(watch{
+		tableName:      AllTables,
+		actionFunction: actionFunction,
+		global:         true,
+		trigger: func(row, previous *Row) bool {
+			return true
+		},
+	}, options...)
+}
+
+// RegisterRouter Register one function per value of routeField, running the one matching each row's value,
+// e.g. {"email": sendEmail, "report": buildReport} on a Type field. Rows with other values are left alone.
+// Like RegisterFunction a row keeps triggering every poll until it is moved off its value, so handlers should
+// change routeField or use WithCompletion
+func (t *Watcher) RegisterRouter(tableName, routeField string, routes map[string]ActionFunction, options ...WatchOption) error {
+	values := make([]string, 0, len(routes))
+	for value := range routes {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	return t.addWatch(watch{
+		tableName:     tableName,
+		fieldName:     routeField,
+		triggerValues: values,
+		actionFunction: func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+			if route, ok := routes[row.GetFieldString(routeField)]; ok {
+				route(ctx, watcher, tableName, row)
+			}
+		},
+	}, options...)
+}
+
+// AttachmentFunction Function that runs when attachments are added to a row, with just the new attachments
+type AttachmentFunction func(ctx context.Context, watcher *Watcher, tableName string, airtableRow *Row, added AirtableAttachments)
+
+// RegisterAttachmentAdded Register a function to run when new files appear in an attachment field between two polls,
+// e.g. to process an uploaded invoice. Attachments are compared by ID, so re-uploading a file counts as new.
+// Files added while the row is held back are passed along once it is dispatched.
+// Rows' attachments as of when the watcher starts do not trigger
+func (t *Watcher) RegisterAttachmentAdded(tableName, fieldName string, action AttachmentFunction, options ...WatchOption) error {
+	return t.addWatch(watch{
+		tableName:    tableName,
+		fieldName:    fieldName,
+		usesPrevious: true,
+		trigger: func(row, previous *Row) bool {
+			return previous != nil && len(addedAttachments(previous, row, fieldName)) > 0
+		},
+		actionFunction: func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+			// Compared again against the row this dispatch triggered from, so nothing is kept between polls
+			action(ctx, watcher, tableName, row, addedAttachments(previousRow(ctx), row, fieldName))
+		},
+	}, options...)
+}
+
+// addedAttachments Get the attachments in row's field that weren't in previous
+func addedAttachments(previous, row *Row, fieldName string) AirtableAttachments {
+	current, err := row.GetFieldAttachments(fieldName)
+	if err != nil || previous == nil {
+		return nil
+	}
+	before, _ := previous.GetFieldAttachments(fieldName)
+	seen := map[string]bool{}
+	for _, attachment := range before {
+		seen[attachment.ID] = true
+	}
+
+	added := AirtableAttachments{}
+	for _, attachment := range current {
+		if !seen[attachment.ID] {
+			added = append(added, attachment)
+		}
+	}
+	return added
+}
@@ -0,0 +1,489 @@
+package airtablewatcher
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// expectRuns polls once and checks how many times the action ran
+func expectRuns(t *testing.T, watcher *Watcher, ran chan string, expected int) {
+	t.Helper()
+	if _, err := watcher.pollCycle(context.Background()); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	got := 0
+	timeout := time.After(time.Millisecond * 100)
+	for {
+		select {
+		case <-ran:
+			got++
+			continue
+		case <-timeout:
+		}
+		break
+	}
+	if got != expected {
+		t.Errorf("Expected %d runs, got %d", expected, got)
+	}
+}
+
+// recordRuns Get an action that reports the row ID of every run
+func recordRuns(ran chan string) ActionFunction {
+	return func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		ran <- row.ID
+	}
+}
+
+func TestRegisterTransition(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	already := fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	processing := fake.AddRecord("Tasks", map[string]interface{}{"State": "Processing"})
+	other := fake.AddRecord("Tasks", map[string]interface{}{"State": "New"})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterTransition("Tasks", "State", []string{"Processing"}, []string{"Done"}, recordRuns(ran))
+
+	// Rows already done should not fire
+	expectRuns(t, watcher, ran, 0)
+
+	// Only the Processing -> Done transition fires
+	fake.SetField("Tasks", processing, "State", "Done")
+	fake.SetField("Tasks", other, "State", "Done")
+	fake.SetField("Tasks", already, "State", "Done")
+	expectRuns(t, watcher, ran, 1)
+
+	// And it doesn't fire again once it stays done
+	expectRuns(t, watcher, ran, 0)
+}
+
+// holdBack Get a guard that holds every row back until the returned function is called
+func holdBack() (GuardFunction, func()) {
+	var allowed int32
+	guard := func(ctx context.Context, watcher *Watcher, row *Row) (bool, error) {
+		return atomic.LoadInt32(&allowed) == 1, nil
+	}
+	return guard, func() { atomic.StoreInt32(&allowed, 1) }
+}
+
+func TestTransitionHeldBack(t *testing.T) {
+	for _, streamPages := range []bool{false, true} {
+		fake := newFakeAirtable()
+		processing := fake.AddRecord("Tasks", map[string]interface{}{"State": "Processing"})
+		watcher := newFakeWatcher(t, fake)
+		watcher.StreamPages = streamPages
+
+		ran := make(chan string, 10)
+		guard, allow := holdBack()
+		watcher.RegisterTransition("Tasks", "State", []string{"Processing"}, []string{"Done"}, recordRuns(ran), WithGuard(guard))
+		expectRuns(t, watcher, ran, 0)
+
+		// Held back the poll it happens, the transition still fires on a later poll
+		fake.SetField("Tasks", processing, "State", "Done")
+		expectRuns(t, watcher, ran, 0)
+		allow()
+		expectRuns(t, watcher, ran, 1)
+		expectRuns(t, watcher, ran, 0)
+		fake.Close()
+	}
+}
+
+func TestDispatchCooldown(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+	watcher.DispatchCooldown = time.Millisecond * 300
+
+	// The action "forgets" to change state, as if the write lagged
+	ran := make(chan string, 10)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, recordRuns(ran))
+
+	expectRuns(t, watcher, ran, 1)
+	watcher.running.Wait()
+	// Still cooling down
+	expectRuns(t, watcher, ran, 0)
+
+	time.Sleep(time.Millisecond * 300)
+	expectRuns(t, watcher, ran, 1)
+}
+
+func TestRegisterThreshold(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"Priority": 9})
+	fake.AddRecord("Tasks", map[string]interface{}{"Priority": 8})
+	fake.AddRecord("Tasks", map[string]interface{}{"Priority": 2})
+	fake.AddRecord("Tasks", map[string]interface{}{"Priority": "not a number"})
+	watcher := newFakeWatcher(t, fake)
+
+	if err := watcher.RegisterThreshold("Tasks", "Priority", "=>", 8, nil); err == nil {
+		t.Errorf("Expected error for unknown comparison")
+	}
+
+	ran := make(chan string, 10)
+	if err := watcher.RegisterThreshold("Tasks", "Priority", GreaterThanOrEqual, 8, recordRuns(ran)); err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	expectRuns(t, watcher, ran, 2)
+
+	// Cancel once the condition no longer holds
+	w := watcher.watchers[0]
+	if !w.canceled(&Row{Fields: map[string]interface{}{"Priority": 3.0}}) || w.canceled(&Row{Fields: map[string]interface{}{"Priority": 10.0}}) {
+		t.Errorf("Incorrect cancel condition")
+	}
+}
+
+func TestWithMinAge(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	now := time.Now().UTC()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "Modified": now.Add(-time.Hour).Format(AirtableDateFormat)})
+	recent := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "Modified": now.Format(AirtableDateFormat)})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, recordRuns(ran), WithMinAge(time.Minute, "Modified"))
+
+	// Only the settled row runs
+	expectRuns(t, watcher, ran, 1)
+	watcher.running.Wait()
+
+	fake.SetField("Tasks", recent, "Modified", now.Add(-time.Hour).Format(AirtableDateFormat))
+	fake.SetField("Tasks", recent, "State", "ToDo")
+	expectRuns(t, watcher, ran, 2)
+}
+
+func TestRegisterPresence(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"Approval": "Already approved"})
+	pending := fake.AddRecord("Tasks", map[string]interface{}{})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterPresence("Tasks", "Approval", recordRuns(ran))
+
+	// Rows already filled in don't fire
+	expectRuns(t, watcher, ran, 0)
+
+	fake.SetField("Tasks", pending, "Approval", "Approved by Sam")
+	expectRuns(t, watcher, ran, 1)
+	expectRuns(t, watcher, ran, 0)
+
+	// Emptying cancels
+	if !watcher.watchers[0].canceled(&Row{Fields: map[string]interface{}{"Approval": ""}}) {
+		t.Errorf("Emptied field should cancel")
+	}
+}
+
+func TestPresenceHeldBack(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	pending := fake.AddRecord("Tasks", map[string]interface{}{})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	guard, allow := holdBack()
+	watcher.RegisterPresence("Tasks", "Approval", recordRuns(ran), WithGuard(guard))
+	expectRuns(t, watcher, ran, 0)
+
+	// Filled in while held back, still fires once allowed
+	fake.SetField("Tasks", pending, "Approval", "Approved by Sam")
+	expectRuns(t, watcher, ran, 0)
+	allow()
+	expectRuns(t, watcher, ran, 1)
+	expectRuns(t, watcher, ran, 0)
+}
+
+func TestWithCompletion(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "Sync"})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"Sync"}, recordRuns(ran), WithCompletion("Synced", "true"))
+
+	// Re-run every poll until complete
+	expectRuns(t, watcher, ran, 1)
+	watcher.running.Wait()
+	expectRuns(t, watcher, ran, 1)
+	watcher.running.Wait()
+
+	fake.SetField("Tasks", id, "Synced", true)
+	expectRuns(t, watcher, ran, 0)
+}
+
+func TestRegisterExpiry(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	old := time.Now().Add(-time.Hour * 2).UTC().Format(AirtableDateFormat)
+	recent := time.Now().UTC().Format(AirtableDateFormat)
+	expired := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "State Changed": old})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "State Changed": recent})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "Done", "State Changed": old})
+	// No timestamp, and only just created
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterExpiry("Tasks", "State", []string{"ToDo"}, time.Hour, "State Changed", recordRuns(ran))
+
+	expectRuns(t, watcher, ran, 1)
+	watcher.running.Wait()
+	fake.SetField("Tasks", expired, "State", "Escalated")
+	expectRuns(t, watcher, ran, 0)
+}
+
+func TestWithNormalizer(t *testing.T) {
+	w := watch{fieldName: "State", triggerValues: []string{"ToDo"}, cancelValues: []string{"Stop"}}
+	WithNormalizer(func(value string) string { return strings.ToLower(strings.TrimSpace(value)) })(&w)
+
+	for value, expected := range map[string]bool{"ToDo": true, " todo ": true, "TODO": true, "Done": false} {
+		row := &Row{Fields: map[string]interface{}{"State": value}}
+		if w.triggered(row, nil) != expected {
+			t.Errorf("Expected %q triggered to be %v", value, expected)
+		}
+	}
+	if !w.canceled(&Row{Fields: map[string]interface{}{"State": "stop "}}) {
+		t.Errorf("Expected normalized cancel value to cancel")
+	}
+}
+
+func TestRegisterAttachmentAdded(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	invoice := map[string]interface{}{"id": "attInvoice", "url": "https://example.com/invoice.pdf", "filename": "invoice.pdf"}
+	id := fake.AddRecord("Tasks", map[string]interface{}{"Files": []interface{}{invoice}})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	added := make(chan AirtableAttachments, 10)
+	watcher.RegisterAttachmentAdded("Tasks", "Files", func(ctx context.Context, watcher *Watcher, tableName string, row *Row, attachments AirtableAttachments) {
+		added <- attachments
+		ran <- row.ID
+	})
+
+	// Existing attachments don't fire
+	expectRuns(t, watcher, ran, 0)
+
+	// A new upload fires with only the new file
+	receipt := map[string]interface{}{"id": "attReceipt", "url": "https://example.com/receipt.pdf", "filename": "receipt.pdf"}
+	fake.SetField("Tasks", id, "Files", []interface{}{invoice, receipt})
+	expectRuns(t, watcher, ran, 1)
+	if got := <-added; len(got) != 1 || got[0].ID != "attReceipt" {
+		t.Errorf("Incorrect added attachments %v", got)
+	}
+
+	// Removing a file does not
+	fake.SetField("Tasks", id, "Files", []interface{}{receipt})
+	expectRuns(t, watcher, ran, 0)
+}
+
+func TestAttachmentAddedHeldBack(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	added := make(chan AirtableAttachments, 10)
+	guard, allow := holdBack()
+	watcher.RegisterAttachmentAdded("Tasks", "Files", func(ctx context.Context, watcher *Watcher, tableName string, row *Row, attachments AirtableAttachments) {
+		added <- attachments
+		ran <- row.ID
+	}, WithGuard(guard))
+	expectRuns(t, watcher, ran, 0)
+
+	// Files uploaded over two held back polls are all passed once allowed
+	invoice := map[string]interface{}{"id": "attInvoice", "url": "https://example.com/invoice.pdf", "filename": "invoice.pdf"}
+	fake.SetField("Tasks", id, "Files", []interface{}{invoice})
+	expectRuns(t, watcher, ran, 0)
+	receipt := map[string]interface{}{"id": "attReceipt", "url": "https://example.com/receipt.pdf", "filename": "receipt.pdf"}
+	fake.SetField("Tasks", id, "Files", []interface{}{invoice, receipt})
+	expectRuns(t, watcher, ran, 0)
+
+	allow()
+	expectRuns(t, watcher, ran, 1)
+	if got := <-added; len(got) != 2 || got[0].ID != "attInvoice" || got[1].ID != "attReceipt" {
+		t.Errorf("Incorrect added attachments %v", got)
+	}
+	expectRuns(t, watcher, ran, 0)
+}
+
+func TestRegisterCompound(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Orders", map[string]interface{}{"Region": "US", "Status": "Approved"})
+	fake.AddRecord("Orders", map[string]interface{}{"Region": "EU", "Status": "Approved"})
+	pending := fake.AddRecord("Orders", map[string]interface{}{"Region": "US", "Status": "Pending"})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterCompound("Orders", []string{"Region", "Status"}, "|", []string{"US|Approved"}, recordRuns(ran))
+	expectRuns(t, watcher, ran, 1)
+
+	fake.SetField("Orders", pending, "Status", "Approved")
+	result, err := watcher.RunOnceResult(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orders := result.Tables["Orders"]; orders.Matched != 2 {
+		t.Errorf("Expected both US approved orders to match, got %+v", orders)
+	}
+}
+
+func TestCountFieldThreshold(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	// Count fields come back as plain numbers
+	done := fake.AddRecord("Projects", map[string]interface{}{"Open Subtasks": float64(2)})
+	fake.AddRecord("Projects", map[string]interface{}{"Open Subtasks": float64(1)})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterThreshold("Projects", "Open Subtasks", Equal, 0, recordRuns(ran))
+	expectRuns(t, watcher, ran, 0)
+
+	fake.SetField("Projects", done, "Open Subtasks", float64(0))
+	expectRuns(t, watcher, ran, 1)
+
+	row, err := watcher.GetRow("Projects", done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := row.GetFieldInt("Open Subtasks"); err != nil || count != 0 {
+		t.Errorf("Incorrect count %d %v", count, err)
+	}
+}
+
+func TestRegisterGlobal(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "Done"})
+	fake.AddRecord("Jobs", map[string]interface{}{"State": "Done"})
+	fake.AddRecord("Unwatched", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	var lock sync.Mutex
+	seen := map[string]int{}
+	watcher.RegisterGlobal(func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		lock.Lock()
+		defer lock.Unlock()
+		seen[tableName]++
+	})
+	ran := make(chan string, 10)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, recordRuns(ran))
+	watcher.RegisterFunction("Jobs", "State", []string{"ToDo"}, recordRuns(ran))
+
+	result, err := watcher.RunOnceResult(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seen, map[string]int{"Tasks": 2, "Jobs": 1}) {
+		t.Errorf("Incorrect global runs %v", seen)
+	}
+	// The table's own function still ran on its row
+	if len(ran) != 1 || result.Tables["Tasks"].Fired != 3 {
+		t.Errorf("Expected the Tasks function to run too, got %d runs %+v", len(ran), result.Tables["Tasks"])
+	}
+}
+
+func TestRegisterRouter(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	email := fake.AddRecord("Tasks", map[string]interface{}{"Type": "email"})
+	report := fake.AddRecord("Tasks", map[string]interface{}{"Type": "report"})
+	fake.AddRecord("Tasks", map[string]interface{}{"Type": "other"})
+	watcher := newFakeWatcher(t, fake)
+
+	var lock sync.Mutex
+	routed := map[string]string{}
+	route := func(name string) ActionFunction {
+		return func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+			lock.Lock()
+			defer lock.Unlock()
+			routed[row.ID] = name
+		}
+	}
+	watcher.RegisterRouter("Tasks", "Type", map[string]ActionFunction{"email": route("sendEmail"), "report": route("buildReport")})
+
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(routed, map[string]string{email: "sendEmail", report: "buildReport"}) {
+		t.Errorf("Incorrect routing %v", routed)
+	}
+}
+
+func TestWithGuard(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	us := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "Region": "US"})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "Region": "EU"})
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	fake.AddRecord("Config", map[string]interface{}{"Key": "region.US.enabled", "Value": "true"})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, recordRuns(ran), WithGuard(func(ctx context.Context, watcher *Watcher, row *Row) (bool, error) {
+		if row.GetFieldString("Region") == "" {
+			return false, errors.New("no region")
+		}
+		enabled, _ := watcher.GetConfig("region." + row.GetFieldString("Region") + ".enabled")
+		return enabled == "true", nil
+	}))
+
+	result, err := watcher.RunOnceResult(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks := result.Tables["Tasks"]; tasks.Fired != 1 || tasks.Skipped != 2 {
+		t.Errorf("Incorrect result %+v", tasks)
+	}
+	if id := <-ran; id != us {
+		t.Errorf("Expected the US row to run, got %s", id)
+	}
+	if fake.ListRequests("Config") != 1 {
+		t.Errorf("Expected config to be read once per poll, got %d", fake.ListRequests("Config"))
+	}
+}
+
+func TestWithMinInterval(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	ran := make(chan string, 10)
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+		ran <- row.ID
+	}, WithMinInterval(time.Millisecond*200))
+
+	if err := watcher.RunOnce(context.Background()); err != nil || len(ran) != 1 {
+		t.Fatalf("Expected the first run, got %d %v", len(ran), err)
+	}
+
+	// Toggled straight back, held back until the interval passes
+	fake.SetField("Tasks", id, "State", "ToDo")
+	result, err := watcher.RunOnceResult(context.Background())
+	if err != nil || result.Tables["Tasks"].Deduped != 1 || len(ran) != 1 {
+		t.Errorf("Expected the row to be held back, got %+v %v", result.Tables["Tasks"], err)
+	}
+	time.Sleep(time.Millisecond * 200)
+	if err := watcher.RunOnce(context.Background()); err != nil || len(ran) != 2 {
+		t.Errorf("Expected a second run after the interval, got %d %v", len(ran), err)
+	}
+}
@@ -0,0 +1,79 @@
+package airtablewatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Unmarshal Decode the row's fields into v, a pointer to a struct, using its json tags.
+// Fields the struct doesn't have are kept in a map[string]interface{} member tagged `airtable:",unknown"` if there is one,
+// e.g. Extra map[string]interface{} `json:"-" airtable:",unknown"`
+func (r *Row) Unmarshal(v interface{}) error {
+	JSON, err := json.Marshal(r.Fields)
+	if err != nil {
+		return fmt.Errorf("error encoding fields: %w", err)
+	}
+	if err := json.Unmarshal(JSON, v); err != nil {
+		return fmt.Errorf("error decoding fields: %w", err)
+	}
+
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	structValue := value.Elem()
+	structType := structValue.Type()
+
+	// Find the catch-all member and the field names the struct decodes
+	unknownIndex := -1
+	known := map[string]bool{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if tag := field.Tag.Get("airtable"); tagHasOption(tag, "unknown") {
+			if field.Type != reflect.TypeOf(map[string]interface{}{}) {
+				return fmt.Errorf("unknown fields member %s must be a map[string]interface{}", field.Name)
+			}
+			unknownIndex = i
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+	if unknownIndex < 0 {
+		return nil
+	}
+
+	// encoding/json matches names case insensitively, so do the same
+	unknown := map[string]interface{}{}
+	fields, _ := r.Fields.(map[string]interface{})
+	for name, fieldValue := range fields {
+		if !known[strings.ToLower(name)] {
+			unknown[name] = fieldValue
+		}
+	}
+	structValue.Field(unknownIndex).Set(reflect.ValueOf(unknown))
+
+	return nil
+}
+
+// tagHasOption Check if a struct tag value like "name,opt1,opt2" has an option
+func tagHasOption(tag, option string) bool {
+	parts := strings.Split(tag, ",")
+	for _, part := range parts[1:] {
+		if part == option {
+			return true
+		}
+	}
+	return false
+}
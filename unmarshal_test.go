@@ -0,0 +1,31 @@
+package airtablewatcher
+
+import (
+	"testing"
+)
+
+func TestRowUnmarshal(t *testing.T) {
+	row := &Row{Fields: map[string]interface{}{"Name": "Task", "State": "ToDo", "Priority": 2.0, "New Column": "x"}}
+
+	task := struct {
+		Name    string
+		State   string                 `json:"State"`
+		Ignored string                 `json:"-"`
+		Extra   map[string]interface{} `json:"-" airtable:",unknown"`
+	}{}
+	if err := row.Unmarshal(&task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Name != "Task" || task.State != "ToDo" {
+		t.Errorf("Incorrect typed fields %+v", task)
+	}
+	if len(task.Extra) != 2 || task.Extra["Priority"] != 2.0 || task.Extra["New Column"] != "x" {
+		t.Errorf("Incorrect unknown fields %v", task.Extra)
+	}
+
+	// Without a catch-all member unknown fields are dropped as before
+	plain := struct{ Name string }{}
+	if err := row.Unmarshal(&plain); err != nil || plain.Name != "Task" {
+		t.Errorf("Incorrect plain unmarshal %+v %v", plain, err)
+	}
+}
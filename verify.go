@@ -0,0 +1,46 @@
+package airtablewatcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// verifyTransitionTimeout is how long verifyTransition keeps re-reading a row still on its trigger value,
+// since airtable can return the old value for a moment after the action's write
+const verifyTransitionTimeout = readAfterWriteInterval * 5
+
+// verifyTransition Check the row's watched field moved off triggerValue after the action returned.
+// Values are compared after the watch's normalizer, like matching does
+func (t *Watcher) verifyTransition(ctx context.Context, recordID string, watcher *watch, triggerValue string) {
+	deadline := time.Now().Add(verifyTransitionTimeout)
+	for {
+		row, err := t.GetRowContext(ctx, watcher.tableName, recordID)
+		if err != nil {
+			t.logEvent(Event{Type: EventError, TableName: watcher.tableName, RecordID: recordID, Detail: fmt.Sprintf("error verifying transition: %s", err)})
+			return
+		}
+		if !watcher.matches([]string{triggerValue}, row) {
+			return
+		}
+		if time.Now().Add(readAfterWriteInterval).After(deadline) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(readAfterWriteInterval):
+		}
+	}
+
+	detail := fmt.Sprintf("%s is still %q after the action finished", watcher.fieldName, triggerValue)
+	t.logEvent(Event{Type: EventNotTransitioned, TableName: watcher.tableName, RecordID: recordID, Detail: detail})
+	if watcher.verifyWarningField == "" {
+		return
+	}
+	warning := fmt.Sprintf("%s: %s", time.Now().UTC().Format(time.RFC3339), detail)
+	if err := t.SetRowContext(ctx, watcher.tableName, recordID, map[string]interface{}{watcher.verifyWarningField: warning}); err != nil {
+		t.logEvent(Event{Type: EventError, TableName: watcher.tableName, RecordID: recordID, Detail: fmt.Sprintf("error writing transition warning: %s", err)})
+	}
+}
@@ -0,0 +1,88 @@
+package airtablewatcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyTransition(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	forgot := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "Name": "forgot"})
+	moved := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo", "Name": "moved"})
+	watcher := newFakeWatcher(t, fake)
+
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		if row.GetFieldString("Name") == "moved" {
+			watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+		}
+	}, WithVerifyTransition("Warning"))
+
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := notTransitioned(watcher); len(ids) != 1 || ids[0] != forgot {
+		t.Errorf("Expected only the forgotten row to be reported, got %v", ids)
+	}
+	if fake.Record("Tasks", forgot)["Warning"] == nil {
+		t.Errorf("Warning field not written")
+	}
+	if fake.Record("Tasks", moved)["Warning"] != nil {
+		t.Errorf("Warning written to a row that transitioned")
+	}
+}
+
+// notTransitioned Get the record IDs of EventNotTransitioned events
+func notTransitioned(watcher *Watcher) []string {
+	ids := []string{}
+	for _, event := range watcher.RecentEvents(0) {
+		if event.Type == EventNotTransitioned {
+			ids = append(ids, event.RecordID)
+		}
+	}
+	return ids
+}
+
+func TestVerifyTransitionReadLag(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	// The write only shows up in reads a little after the action returns
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		go func() {
+			time.Sleep(readAfterWriteInterval * 2)
+			fake.SetField(tableName, row.ID, "State", "Done")
+		}()
+	}, WithVerifyTransition(""))
+
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if ids := notTransitioned(watcher); len(ids) != 0 {
+		t.Errorf("Lagging write reported as not transitioned: %v", ids)
+	}
+}
+
+func TestVerifyTransitionNormalized(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	recased := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	watcher := newFakeWatcher(t, fake)
+
+	// Only changing the case leaves the row on its trigger value
+	watcher.RegisterFunctionWithOptions("Tasks", "State", []string{"todo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": " TODO"})
+	}, WithVerifyTransition(""), WithNormalizer(func(value string) string { return strings.ToLower(strings.TrimSpace(value)) }))
+
+	if err := watcher.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if ids := notTransitioned(watcher); len(ids) != 1 || ids[0] != recased {
+		t.Errorf("Expected the recased row to be reported, got %v", ids)
+	}
+}
@@ -0,0 +1,105 @@
+package airtablewatcher
+
+import "time"
+
+// WatchOption configures a single registered function
+type WatchOption func(*watch)
+
+// WithCancelValues Cancel the function when the field changes to any of the cancelValues
+func WithCancelValues(cancelValues ...string) WatchOption {
+	return func(w *watch) {
+		w.cancelValues = append(w.cancelValues, cancelValues...)
+	}
+}
+
+// WithEnabledConfigKey Bind the function to a key in the config table, e.g. "watch.tasks.enabled".
+// Each poll cycle the key is read, and when its value is "false" rows will not be dispatched to this function.
+// A missing key leaves the function enabled
+func WithEnabledConfigKey(key string) WatchOption {
+	return func(w *watch) {
+		w.enabledConfigKey = key
+	}
+}
+
+// WithMinAge Only trigger on rows that haven't been edited for at least minAge, so half-entered data is left alone.
+// The last edit time is read from lastModifiedField (a "Last modified time" field), falling back to the record's
+// metadata (see Row.LastModified) and then to when the row was created
+func WithMinAge(minAge time.Duration, lastModifiedField string) WatchOption {
+	return func(w *watch) {
+		w.minAge = minAge
+		w.lastModifiedField = lastModifiedField
+	}
+}
+
+// WithOnShutdown Run shutdownFunction once when the watcher stops, after in-flight actions have drained
+func WithOnShutdown(shutdownFunction ShutdownFunction) WatchOption {
+	return func(w *watch) {
+		w.onShutdown = shutdownFunction
+	}
+}
+
+// WithMaxConcurrency Run at most n of this function's actions at once, on top of the table and global limits
+func WithMaxConcurrency(n int) WatchOption {
+	return func(w *watch) {
+		if n > 0 {
+			w.semaphore = make(semaphore, n)
+		}
+	}
+}
+
+// WithVerifyTransition Re-read the row after the action returns and log an EventNotTransitioned if the watched field
+// is still on the value that triggered it, which usually means the action forgot to SetRow.
+// The row is re-read for a moment to allow for airtable's read after write lag, comparing values after WithNormalizer.
+// If warningField is set, a warning is also written to that field of the row
+func WithVerifyTransition(warningField string) WatchOption {
+	return func(w *watch) {
+		w.verifyTransition = true
+		w.verifyWarningField = warningField
+	}
+}
+
+// WithMaxRuntimeField Cancel the action once it has run longer than the duration in the row's field.
+// The field is re-read while the action runs, see Row.GetFieldDuration for the formats accepted.
+// Rows with the field empty have no limit
+func WithMaxRuntimeField(fieldName string) WatchOption {
+	return func(w *watch) {
+		w.maxRuntimeField = fieldName
+	}
+}
+
+// WithCompletion Stop triggering once completionField reads completionValue, for idempotent actions that
+// should be retried every poll until an eventually consistent system reports them done.
+// Rows are still only dispatched once at a time, a row whose action is running is skipped until it returns
+func WithCompletion(completionField, completionValue string) WatchOption {
+	return func(w *watch) {
+		w.completionField = completionField
+		w.completionValue = completionValue
+	}
+}
+
+// WithNormalizer Compare the field against the trigger and cancel values after passing both through normalize,
+// e.g. strings.ToLower after strings.TrimSpace for values typed in by hand.
+// Custom triggers such as RegisterThreshold do their own comparison and ignore it
+func WithNormalizer(normalize func(string) string) WatchOption {
+	return func(w *watch) {
+		w.normalizer = normalize
+	}
+}
+
+// WithMinInterval Dispatch the same row at most once every interval, counted from when each run starts, even if
+// its field changes back and forth in between, e.g. to stop a button being clicked repeatedly.
+// Unlike Watcher.DispatchCooldown it ignores the trigger value. Rows triggering too soon are skipped until it passes
+func WithMinInterval(interval time.Duration) WatchOption {
+	return func(w *watch) {
+		w.minInterval = interval
+	}
+}
+
+// WithGuard Check guard before dispatching each triggered row, skipping the row this cycle if it returns false
+// or an error, e.g. to only process rows whose Region is enabled in the config table.
+// Config reads during a poll are cached for the cycle, so a guard calling GetConfig costs one read per poll
+func WithGuard(guard GuardFunction) WatchOption {
+	return func(w *watch) {
+		w.guard = guard
+	}
+}
@@ -0,0 +1,195 @@
+package airtablewatcher
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Defaults for webhooks
+const (
+	// Airtable webhooks expire after 7 days unless refreshed
+	DefaultWebhookRefreshInterval = time.Hour * 24
+	DefaultWebhookFallbackPoll    = time.Minute * 5
+)
+
+// WebhookOptions configures StartWebhook
+type WebhookOptions struct {
+	// Public URL airtable sends notifications to, it must reach ListenAddr
+	NotificationURL string
+	// Address the notification server listens on, e.g. ":8080"
+	ListenAddr string
+	// Poll anyway at this interval in case a notification is missed, defaults to DefaultWebhookFallbackPoll
+	FallbackPollInterval time.Duration
+}
+
+// webhook is a registered airtable webhook
+type webhook struct {
+	ID              string `json:"id"`
+	MacSecretBase64 string `json:"macSecretBase64"`
+}
+
+// StartWebhook watch airtable for triggers using webhook notifications instead of polling every PollInterval, blocking function.
+//
+// A webhook is registered for the base and an HTTP server is started on ListenAddr. Every notification runs the same
+// trigger and action dispatch as Start, including Leader and DrainMode. If the webhook can't be registered, this
+// falls back to Start. The webhook is deleted when the context is canceled, or when the HTTP server fails, which is
+// logged and returned
+func (t *Watcher) StartWebhook(ctx context.Context, options WebhookOptions) error {
+	hook, err := t.createWebhook(ctx, options.NotificationURL)
+	if err != nil {
+		t.logEvent(Event{Type: EventError, Detail: fmt.Sprintf("webhook registration failed, polling instead: %s", err)})
+		return t.Start(ctx)
+	}
+	defer func() {
+		if _, err := t.request(context.Background(), "DELETE", "bases/"+t.airtableBase+"/webhooks/"+hook.ID, nil, nil); err != nil {
+			t.logEvent(Event{Type: EventError, Detail: fmt.Sprintf("error deleting webhook: %s", err)})
+		}
+	}()
+
+	secret, err := base64.StdEncoding.DecodeString(hook.MacSecretBase64)
+	if err != nil {
+		return fmt.Errorf("error decoding webhook secret: %w", err)
+	}
+
+	// Serve notifications
+	listener, err := net.Listen("tcp", options.ListenAddr)
+	if err != nil {
+		return err
+	}
+	notify := make(chan struct{}, 1)
+	server := &http.Server{Handler: webhookHandler(secret, notify)}
+	serveErr := serveNotifications(server, listener)
+	defer server.Close()
+
+	fallback := options.FallbackPollInterval
+	if fallback <= 0 {
+		fallback = DefaultWebhookFallbackPoll
+	}
+	fallbackTicker := time.NewTicker(fallback)
+	defer fallbackTicker.Stop()
+	refreshTicker := time.NewTicker(DefaultWebhookRefreshInterval)
+	defer refreshTicker.Stop()
+	// Standby watchers keep checking for leadership between notifications, and leaders keep their lease
+	var leaderTick <-chan time.Time
+	if t.Leader != nil && t.PollInterval > 0 {
+		leaderTicker := time.NewTicker(t.PollInterval)
+		defer leaderTicker.Stop()
+		leaderTick = leaderTicker.C
+	}
+
+	actionCtx, cancelActions := t.actionContext(ctx)
+	defer cancelActions()
+
+	burst := 0
+	for {
+		fired, err := t.pollIfLeading(actionCtx)
+		if err != nil {
+			return err
+		}
+		if t.drainBurst(fired, &burst) {
+			continue
+		}
+
+		// Wait for a notification, or stop
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				t.shutdown()
+				return ctx.Err()
+			case <-t.stopped:
+				t.shutdown()
+				return nil
+			case err := <-serveErr:
+				// Notifications can't arrive any more
+				err = fmt.Errorf("webhook server stopped: %w", err)
+				t.logEvent(Event{Type: EventError, Detail: err.Error()})
+				t.shutdown()
+				return err
+			case <-refreshTicker.C:
+				if _, err := t.request(ctx, "POST", "bases/"+t.airtableBase+"/webhooks/"+hook.ID+"/refresh", nil, map[string]interface{}{}); err != nil {
+					t.logEvent(Event{Type: EventError, Detail: fmt.Sprintf("error refreshing webhook: %s", err)})
+				}
+			case <-leaderTick:
+				t.Lock()
+				leading := t.leading
+				t.Unlock()
+				// Poll straight away on taking over
+				if t.lead(ctx) && !leading {
+					break wait
+				}
+			case <-notify:
+				break wait
+			case <-fallbackTicker.C:
+				break wait
+			}
+		}
+	}
+}
+
+// serveNotifications Serve webhook notifications on listener in the background,
+// sending the error if the server stops for any reason other than being closed
+func serveNotifications(server *http.Server, listener net.Listener) <-chan error {
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+	return serveErr
+}
+
+// createWebhook Register a webhook for changes to table data in this base
+func (t *Watcher) createWebhook(ctx context.Context, notificationURL string) (*webhook, error) {
+	body := map[string]interface{}{
+		"notificationUrl": notificationURL,
+		"specification": map[string]interface{}{
+			"options": map[string]interface{}{
+				"filters": map[string]interface{}{"dataTypes": []string{"tableData"}},
+			},
+		},
+	}
+	rawBody, err := t.request(ctx, "POST", "bases/"+t.airtableBase+"/webhooks", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	hook := &webhook{}
+	if err := json.Unmarshal(rawBody, hook); err != nil {
+		return nil, fmt.Errorf("error decoding webhook: %w", err)
+	}
+	return hook, nil
+}
+
+// webhookHandler Get a handler that checks a notification's signature and signals notify.
+// Notifications that arrive while one is already pending are merged
+func webhookHandler(secret []byte, notify chan<- struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		expected := "hmac-sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Airtable-Content-MAC"))) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,206 @@
+package airtablewatcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vertoforce/airtablewatcher/internal/fakeairtable"
+)
+
+// sendNotification posts a signed webhook notification
+func sendNotification(url string, secret []byte) (*http.Response, error) {
+	body := []byte(`{"base": {"id": "appTESTTESTTESTTE"}, "webhook": {"id": "achTEST"}, "timestamp": "2020-01-01T00:00:00.000Z"}`)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+	req.Header.Set("X-Airtable-Content-MAC", "hmac-sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return http.DefaultClient.Do(req)
+}
+
+func TestStartWebhook(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	watcher := newFakeWatcher(t, fake)
+
+	// Find a free port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ran := make(chan string, 10)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+		ran <- row.ID
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.StartWebhook(ctx, WebhookOptions{NotificationURL: "http://" + addr, ListenAddr: addr, FallbackPollInterval: time.Hour})
+	}()
+
+	// Wait for registration
+	for i := 0; len(fake.Webhooks()) == 0; i++ {
+		if i > 100 {
+			t.Fatal("Webhook was not registered")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	time.Sleep(time.Millisecond * 50)
+
+	// Bad signatures are rejected
+	if resp, err := sendNotification("http://"+addr, []byte("wrong")); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned notification to be rejected")
+	}
+
+	// A notification runs a poll
+	id := fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	if resp, err := sendNotification("http://"+addr, fakeairtable.WebhookSecret); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Notification failed: %v", err)
+	}
+	select {
+	case ranID := <-ran:
+		if ranID != id {
+			t.Errorf("Ran on wrong row")
+		}
+	case <-time.After(time.Second * 2):
+		t.Errorf("Notification did not trigger the action")
+	}
+
+	// The webhook is deleted on shutdown
+	cancel()
+	<-done
+	if len(fake.Webhooks()) != 0 {
+		t.Errorf("Webhook was not deleted")
+	}
+}
+
+func TestServeNotifications(t *testing.T) {
+	// Closing the server is a normal stop
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.NotFoundHandler()}
+	serveErr := serveNotifications(server, listener)
+	time.Sleep(time.Millisecond * 10)
+	server.Close()
+	select {
+	case err := <-serveErr:
+		t.Errorf("Closing the server reported %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// Anything else is reported
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	select {
+	case err := <-serveNotifications(&http.Server{Handler: http.NotFoundHandler()}, listener):
+		if err == nil {
+			t.Errorf("Expected the serve error")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Serve error was not reported")
+	}
+}
+
+func TestStartWebhookFallback(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	watcher := newFakeWatcher(t, fake)
+	watcher.PollInterval = time.Millisecond * 10
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+
+	ran := make(chan string, 10)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+		ran <- row.ID
+	})
+
+	// Registration fails so it polls instead
+	fake.FailNext(http.StatusForbidden, "NOT_AUTHORIZED")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	go watcher.StartWebhook(ctx, WebhookOptions{NotificationURL: "http://localhost", ListenAddr: "127.0.0.1:0"})
+	select {
+	case <-ran:
+	case <-ctx.Done():
+		t.Errorf("Did not fall back to polling")
+	}
+}
+
+func TestStartWebhookLeader(t *testing.T) {
+	fake := newFakeAirtable()
+	defer fake.Close()
+	primary := newFakeWatcher(t, fake)
+	primary.WorkerID = "primary"
+	primaryLock := NewConfigLeaderLock(primary, "leader")
+	if leading, err := primaryLock.Acquire(context.Background(), primary.WorkerID); err != nil || !leading {
+		t.Fatalf("Expected primary to lead: %v %v", leading, err)
+	}
+	watcher := newFakeWatcher(t, fake)
+	watcher.WorkerID = "standby"
+	watcher.PollInterval = time.Millisecond * 20
+	watcher.Leader = NewConfigLeaderLock(watcher, "leader")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ran := make(chan string, 10)
+	watcher.RegisterFunction("Tasks", "State", []string{"ToDo"}, func(ctx context.Context, watcher *Watcher, tableName string, row *Row) {
+		watcher.SetRow(tableName, row.ID, map[string]interface{}{"State": "Done"})
+		ran <- row.ID
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.StartWebhook(ctx, WebhookOptions{NotificationURL: "http://" + addr, ListenAddr: addr, FallbackPollInterval: time.Hour})
+	}()
+	for i := 0; len(fake.Webhooks()) == 0; i++ {
+		if i > 100 {
+			t.Fatal("Webhook was not registered")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	time.Sleep(time.Millisecond * 50)
+
+	// A standby doesn't dispatch on notifications
+	fake.AddRecord("Tasks", map[string]interface{}{"State": "ToDo"})
+	if resp, err := sendNotification("http://"+addr, fakeairtable.WebhookSecret); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Notification failed: %v", err)
+	}
+	select {
+	case <-ran:
+		t.Errorf("Standby dispatched while another watcher leads")
+	case <-time.After(time.Millisecond * 200):
+	}
+
+	// It polls once it takes over
+	if err := primaryLock.Release(context.Background(), primary.WorkerID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second * 2):
+		t.Errorf("Did not poll after taking over leadership")
+	}
+	cancel()
+	<-done
+}